@@ -0,0 +1,79 @@
+package quarry
+
+import "fmt"
+
+func (q *quarryImpl) MustAddAlias(alias, target string) {
+	if err := q.AddAlias(alias, target); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) AddAlias(alias, target string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.frozen.Load() {
+		return fmt.Errorf("cannot add alias %s to a frozen quarry", alias)
+	}
+	if _, exists := q.factories[alias]; exists {
+		return fmt.Errorf("duplicate add of factory %s", alias)
+	}
+	if q.adjacency[alias].Size() > 0 {
+		return fmt.Errorf("alias %s cannot have dependencies", alias)
+	}
+	if path := q.findCycle(alias, target); path != nil {
+		return &CycleError{Parent: alias, DependsOn: target, Path: path}
+	}
+	q.link(alias, target)
+	q.factories[alias] = &node{alias: target}
+	return nil
+}
+
+// isAlias returns true if the node was registered with AddAlias.
+func (n *node) isAlias() bool {
+	return n.alias != ""
+}
+
+// canonical follows aliases from name to the node they stand for.
+// If the aliases form a cycle, which a child and its parent can do together,
+// it returns an alias on the cycle.
+func (q *quarryImpl) canonical(name string) string {
+	seen := newStringSet()
+	for {
+		n, ok := q.lookup(name)
+		if !ok || !n.isAlias() || seen.Contains(name) {
+			return name
+		}
+		seen.Add(name)
+		name = n.alias
+	}
+}
+
+// aliasCycle describes the cycle formed by the aliases starting at name.
+func (q *quarryImpl) aliasCycle(name string) *CycleError {
+	path := []string{name}
+	for target := name; ; {
+		n, _ := q.lookup(target)
+		target = n.alias
+		path = append(path, target)
+		if target == name {
+			return &CycleError{Parent: name, DependsOn: path[1], Path: path}
+		}
+	}
+}
+
+// lookup finds the node registered under name in this quarry or its parents.
+func (q *quarryImpl) lookup(name string) (*node, bool) {
+	var n *node
+	var ok bool
+	if q.frozen.Load() {
+		n, ok = q.factories[name]
+	} else {
+		q.mu.RLock()
+		n, ok = q.factories[name]
+		q.mu.RUnlock()
+	}
+	if !ok && q.parent != nil {
+		return q.parent.lookup(name)
+	}
+	return n, ok
+}
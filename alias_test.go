@@ -0,0 +1,143 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarryImpl_AddAlias(t *testing.T) {
+	q := quarry.New()
+	count, counter := factoryCounter()
+	q.MustAddFactory("userStorage", counter)
+	q.MustAddAlias("storage", "userStorage")
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddDependency("root", "storage")
+	q.MustAddDependency("root", "userStorage")
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"storage": int32(1), "userStorage": int32(1)}, value)
+	assert.Equal(t, int32(1), *count)
+}
+
+func TestQuarryImpl_AddAlias_chained(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("userStorage", factoryValue("users"))
+	q.MustAddAlias("storage", "userStorage")
+	q.MustAddAlias("store", "storage")
+
+	value, err := q.Get(context.Background(), nil, "store")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "users", value)
+	assert.True(t, q.Has("store"))
+}
+
+func TestQuarryImpl_AddAlias_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(q quarry.Quarry) error
+		err   string
+	}{
+		{"duplicate", func(q quarry.Quarry) error {
+			q.MustAddFactory("storage", factoryOk())
+			return q.AddAlias("storage", "userStorage")
+		}, "duplicate add of factory storage"},
+		{"dependencies before", func(q quarry.Quarry) error {
+			q.MustAddDependency("storage", "db")
+			return q.AddAlias("storage", "userStorage")
+		}, "alias storage cannot have dependencies"},
+		{"dependencies after", func(q quarry.Quarry) error {
+			q.MustAddAlias("storage", "userStorage")
+			return q.AddDependency("storage", "db")
+		}, "alias storage cannot have dependencies"},
+		{"fallback", func(q quarry.Quarry) error {
+			q.MustAddAlias("storage", "userStorage")
+			return q.AddFallback("storage", factoryOk())
+		}, "cannot add fallback for alias storage"},
+		{"cycle", func(q quarry.Quarry) error {
+			q.MustAddAlias("storage", "userStorage")
+			return q.AddAlias("userStorage", "storage")
+		}, "depending userStorage on storage creates a cycle: userStorage -> storage -> userStorage"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := quarry.New()
+
+			err := test.setup(q)
+
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestQuarryImpl_AddAlias_validatesTarget(t *testing.T) {
+	q := quarry.New()
+	q.MustAddAlias("storage", "userStorage")
+
+	err := q.Validate()
+
+	assert.EqualError(t, err, "invalid quarry: factory userStorage, depended upon by storage, does not exist")
+}
+
+func TestAs(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("primaryDB", factoryValue("primary"))
+	q.MustAddFactory("replicaDB", factoryValue("replica"))
+	q.MustAddFactory("writer", factoryDeps())
+	q.MustAddFactory("reader", factoryDeps())
+	q.MustAddEdge("writer", "primaryDB", quarry.As("db"))
+	q.MustAddEdge("reader", "replicaDB", quarry.As("db"))
+
+	values, err := q.GetAll(context.Background(), nil, "writer", "reader")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{
+		"writer": quarry.Dependencies{"db": "primary"},
+		"reader": quarry.Dependencies{"db": "replica"},
+	}, values)
+}
+
+func TestAs_statusUsesLocalKey(t *testing.T) {
+	q := quarry.New()
+	var skipped bool
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		skipped = quarry.Skipped(ctx, "db")
+		return nil, nil
+	})
+	q.MustAddFactory("primaryDB", factoryOk())
+	q.MustAddEdge("root", "primaryDB", quarry.As("db"), quarry.WhenAll(no))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.True(t, skipped)
+}
+
+func TestAs_conflictingKeys(t *testing.T) {
+	q := quarry.New()
+	q.MustAddEdge("root", "primaryDB", quarry.As("db"))
+
+	err := q.AddEdge("root", "replicaDB", quarry.As("db"))
+	conflictErr := q.AddEdge("root", "db")
+
+	assert.EqualError(t, err, "dependency of root on replicaDB uses key db, which is already used by primaryDB")
+	assert.EqualError(t, conflictErr, "dependency of root on db uses key db, which is already used by primaryDB")
+}
+
+func TestQuarryImpl_AddAlias_targetFailure(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("userStorage", factoryError())
+	q.MustAddAlias("storage", "userStorage")
+
+	_, err := q.Get(context.Background(), nil, "storage")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, "userStorage", resolutionErr.Node)
+}
@@ -0,0 +1,146 @@
+package quarry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a Factory is not called because its
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed means the Factory is called normally.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the Factory has failed repeatedly and is not called.
+	CircuitOpen
+	// CircuitHalfOpen means the cool-down has passed and the next call will
+	// decide whether the circuit closes or opens again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// WithCircuitBreaker stops calling a Factory once it fails threshold times in
+// a row. While the circuit is open, resolutions that need the Factory fail
+// immediately with ErrCircuitOpen. After the cool-down, a single call is let
+// through: if it succeeds the circuit closes, otherwise it opens again.
+// Failures caused by the resolution's Context being done are not counted.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) FactoryOption {
+	return func(n *node) {
+		n.breaker = newCircuitBreaker(threshold, cooldown)
+	}
+}
+
+// circuitBreaker tracks the failures of a Factory.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu sync.Mutex
+	// state is the state of the circuit. An open circuit whose cool-down has
+	// passed only becomes half-open once a call is let through.
+	state CircuitState
+	// failures is the number of consecutive failures while closed.
+	failures int
+	// openedAt is when the circuit last opened.
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow returns ErrCircuitOpen if the Factory should not be called.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		return nil
+	case CircuitHalfOpen:
+		// A trial call is already in progress.
+		return ErrCircuitOpen
+	default:
+		return nil
+	}
+}
+
+// record updates the circuit with the result of a call that was allowed.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case err == nil:
+		b.state = CircuitClosed
+		b.failures = 0
+	case ctx.Err() != nil:
+		// The call was abandoned rather than failed.
+		if b.state == CircuitHalfOpen {
+			b.state = CircuitOpen
+		}
+	case b.state == CircuitHalfOpen:
+		b.open()
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// open opens the circuit. The caller must hold mu.
+func (b *circuitBreaker) open() {
+	b.state = CircuitOpen
+	b.failures = 0
+	b.openedAt = time.Now()
+}
+
+// currentState reports the state of the circuit, treating an open circuit
+// whose cool-down has passed as half-open.
+func (b *circuitBreaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (q *quarryImpl) CircuitStates() map[string]CircuitState {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	states := make(map[string]CircuitState)
+	for _, name := range q.registered() {
+		if n, _, _ := q.lookupLocked(name); n.breaker != nil {
+			states[name] = n.breaker.currentState()
+		}
+	}
+	return states
+}
@@ -0,0 +1,97 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCircuitBreaker_opensAfterThreshold(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(5)
+	q.MustAddFactory("root", flaky, quarry.WithCircuitBreaker(2, time.Minute))
+
+	q.Get(context.Background(), nil, "root")
+	q.Get(context.Background(), nil, "root")
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.True(t, errors.Is(err, quarry.ErrCircuitOpen))
+	assert.Equal(t, int32(2), *calls)
+	assert.Equal(t, map[string]quarry.CircuitState{"root": quarry.CircuitOpen}, q.CircuitStates())
+}
+
+func TestWithCircuitBreaker_closesAfterSuccessfulTrial(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(2)
+	q.MustAddFactory("root", flaky, quarry.WithCircuitBreaker(2, 10*time.Millisecond))
+	q.Get(context.Background(), nil, "root")
+	q.Get(context.Background(), nil, "root")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, quarry.CircuitHalfOpen, q.CircuitStates()["root"])
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", value)
+	assert.Equal(t, int32(3), *calls)
+	assert.Equal(t, quarry.CircuitClosed, q.CircuitStates()["root"])
+}
+
+func TestWithCircuitBreaker_reopensAfterFailedTrial(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(5)
+	q.MustAddFactory("root", flaky, quarry.WithCircuitBreaker(2, 10*time.Millisecond))
+	q.Get(context.Background(), nil, "root")
+	q.Get(context.Background(), nil, "root")
+	time.Sleep(20 * time.Millisecond)
+
+	q.Get(context.Background(), nil, "root")
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.True(t, errors.Is(err, quarry.ErrCircuitOpen))
+	assert.Equal(t, int32(3), *calls)
+}
+
+func TestWithCircuitBreaker_successResetsFailures(t *testing.T) {
+	q := quarry.New()
+	fail := true
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		if fail {
+			return nil, errors.New("some-error")
+		}
+		return "ok", nil
+	}, quarry.WithCircuitBreaker(2, time.Minute))
+
+	q.Get(context.Background(), nil, "root")
+	fail = false
+	q.Get(context.Background(), nil, "root")
+	fail = true
+	q.Get(context.Background(), nil, "root")
+
+	assert.Equal(t, quarry.CircuitClosed, q.CircuitStates()["root"])
+}
+
+func TestQuarryImpl_CircuitStates_onlyIncludesBreakers(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("plain", factoryOk())
+
+	assert.Empty(t, q.CircuitStates())
+}
+
+func TestQuarryImpl_CircuitStates_includesInheritedBreakers(t *testing.T) {
+	parent := quarry.New()
+	_, flaky := factoryFlaky(5)
+	parent.MustAddFactory("root", flaky, quarry.WithCircuitBreaker(2, time.Minute))
+	child := parent.Child()
+	child.MustAddFactory("local", factoryOk())
+	parent.Get(context.Background(), nil, "root")
+	parent.Get(context.Background(), nil, "root")
+
+	states := child.CircuitStates()
+
+	assert.Equal(t, map[string]quarry.CircuitState{"root": quarry.CircuitOpen}, states)
+}
@@ -0,0 +1,165 @@
+package quarry
+
+import "reflect"
+
+func (q *quarryImpl) Child() Quarry {
+	child := New().(*quarryImpl)
+	child.parent = q
+	child.depth = q.depth + 1
+	return child
+}
+
+// lookupLocked finds the node registered under name and its dependencies.
+// Nodes that are not overridden are looked up in the parent quarry. A node is
+// overridden when its Factory is registered or its dependencies are declared
+// in this quarry, in which case only the dependencies declared in this quarry
+// are used, and the parent's Factory is used only if none is registered here.
+// The caller must hold mu.
+func (q *quarryImpl) lookupLocked(name string) (n *node, edges edgeMap, ok bool) {
+	n, ok = q.factories[name]
+	edges, declared := q.adjacency[name]
+	if q.parent != nil && !ok {
+		var parentEdges edgeMap
+		n, parentEdges, ok = q.parent.node(name)
+		if !declared {
+			edges = parentEdges
+		}
+	}
+	return n, edges, ok
+}
+
+// overrides returns true if the node is overridden in this quarry.
+func (q *quarryImpl) overrides(name string) bool {
+	if q.frozen.Load() {
+		return q.overridesLocked(name)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.overridesLocked(name)
+}
+
+// overridesLocked is like overrides.
+// The caller must hold mu.
+func (q *quarryImpl) overridesLocked(name string) bool {
+	_, ok := q.factories[name]
+	_, declared := q.adjacency[name]
+	return ok || declared
+}
+
+// names returns the names of every node registered or depended upon in this
+// quarry or its parents.
+// The caller must hold mu.
+func (q *quarryImpl) names() stringSet {
+	var names stringSet
+	if q.parent != nil {
+		q.parent.mu.RLock()
+		names = q.parent.names()
+		q.parent.mu.RUnlock()
+	} else {
+		names = newStringSet()
+	}
+	for name := range q.factories {
+		names.Add(name)
+	}
+	for name := range q.adjacency {
+		names.Add(name)
+	}
+	return names
+}
+
+// allTypes returns the types of values produced by the factories of this
+// quarry and its parents, except those of overridden nodes.
+// The caller must hold mu.
+func (q *quarryImpl) allTypes() map[string]reflect.Type {
+	types := make(map[string]reflect.Type)
+	if q.parent != nil {
+		q.parent.mu.RLock()
+		parentTypes := q.parent.allTypes()
+		q.parent.mu.RUnlock()
+		for name, t := range parentTypes {
+			if !q.overridesLocked(name) {
+				types[name] = t
+			}
+		}
+	}
+	for name, t := range q.types {
+		types[name] = t
+	}
+	return types
+}
+
+// findCycle searches the graph for a path from dependsOn back to parent,
+// which a new edge from parent to dependsOn would close. It returns the
+// names of the nodes forming the cycle, beginning and ending with parent, or
+// nil if there is none.
+// The caller must hold mu.
+func (q *quarryImpl) findCycle(parent, dependsOn string) []string {
+	if q.parent == nil {
+		return q.order.insert(q.successors, q.dependents, parent, dependsOn)
+	}
+	// Children usually override only a handful of nodes, so rather than
+	// maintaining an order of the combined graph, it is searched directly.
+	if parent == dependsOn {
+		return []string{parent, parent}
+	}
+	via := map[string]string{dependsOn: ""}
+	stack := []string{dependsOn}
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n, edges, _ := q.lookupLocked(name)
+		for _, next := range successorsOf(n, edges) {
+			if _, seen := via[next]; seen {
+				continue
+			}
+			via[next] = name
+			if next == parent {
+				return cyclePath(via, parent, dependsOn)
+			}
+			stack = append(stack, next)
+		}
+	}
+	return nil
+}
+
+// findAnyCycle returns the names of the nodes forming a cycle reachable from
+// names, beginning and ending with the same node, or nil if there is none.
+// The caller must hold mu.
+func (q *quarryImpl) findAnyCycle(names []string) []string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range stack {
+				if n == name {
+					return append(append([]string(nil), stack[i:]...), name)
+				}
+			}
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		n, edges, _ := q.lookupLocked(name)
+		for _, next := range successorsOf(n, edges) {
+			if cycle := visit(next); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
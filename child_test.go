@@ -0,0 +1,217 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+// singletonCounter returns a Singleton that counts how many times it is created.
+func singletonCounter() (*int32, quarry.Factory) {
+	var count int32
+	factory := quarry.Singleton(func(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+		return atomic.AddInt32(&count, 1), nil
+	})
+	return &count, factory
+}
+
+func TestQuarryImpl_Child_fallsBackToParent(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddFactory("secret", factoryValue("parent"))
+	q.MustAddDependency("root", "secret")
+	child := q.Child()
+
+	value, err := child.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"secret": "parent"}, value)
+	assert.True(t, child.Has("secret"))
+}
+
+func TestQuarryImpl_Child_overridesNode(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddFactory("secret", factoryValue("parent"))
+	q.MustAddDependency("root", "secret")
+	child := q.Child()
+	child.MustAddFactory("secret", factoryValue("child"))
+
+	childValue, childErr := child.Get(context.Background(), nil, "root")
+	parentValue, parentErr := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, childErr)
+	assert.NoError(t, parentErr)
+	assert.Equal(t, quarry.Dependencies{"secret": "child"}, childValue)
+	assert.Equal(t, quarry.Dependencies{"secret": "parent"}, parentValue)
+}
+
+func TestQuarryImpl_Child_overriddenNodeUsesOwnDependencies(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("client", factoryDeps())
+	q.MustAddFactory("address", factoryValue("parent"))
+	q.MustAddFactory("testAddress", factoryValue("child"))
+	q.MustAddDependency("client", "address")
+	child := q.Child()
+	child.MustAddEdge("client", "testAddress", quarry.As("address"))
+
+	value, err := child.Get(context.Background(), nil, "client")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"address": "child"}, value)
+}
+
+func TestQuarryImpl_Child_singletons(t *testing.T) {
+	q := quarry.New()
+	dbCount, db := singletonCounter()
+	serviceCount, service := singletonCounter()
+	q.MustAddFactory("db", db)
+	q.MustAddFactory("secret", factoryValue("parent"))
+	q.MustAddFactory("service", service)
+	q.MustAddDependency("service", "db")
+	q.MustAddDependency("service", "secret")
+	child := q.Child()
+	child.MustAddFactory("secret", factoryValue("child"))
+	grandchild := child.Child()
+
+	parentValues, parentErr := q.GetAll(context.Background(), nil, "db", "service")
+	childValues, childErr := child.GetAll(context.Background(), nil, "db", "service")
+	grandchildValues, grandchildErr := grandchild.GetAll(context.Background(), nil, "db", "service")
+	childAgain, childAgainErr := child.GetAll(context.Background(), nil, "db", "service")
+
+	assert.NoError(t, parentErr)
+	assert.NoError(t, childErr)
+	assert.NoError(t, grandchildErr)
+	assert.NoError(t, childAgainErr)
+	assert.Equal(t, quarry.Dependencies{"db": int32(1), "service": int32(1)}, parentValues)
+	assert.Equal(t, quarry.Dependencies{"db": int32(1), "service": int32(2)}, childValues)
+	assert.Equal(t, childValues, grandchildValues)
+	assert.Equal(t, childValues, childAgain)
+	assert.Equal(t, int32(1), *dbCount)
+	assert.Equal(t, int32(2), *serviceCount)
+}
+
+func TestQuarryImpl_Child_singletonsReleasedWithChild(t *testing.T) {
+	q := quarry.New()
+	_, service := singletonCounter()
+	q.MustAddFactory("secret", factoryValue("parent"))
+	q.MustAddFactory("service", service)
+	q.MustAddDependency("service", "secret")
+	child := q.Child()
+	child.MustAddFactory("secret", factoryValue("child"))
+	_, err := child.Get(context.Background(), nil, "service")
+	released := make(chan struct{})
+	runtime.SetFinalizer(child, func(quarry.Quarry) { close(released) })
+	child = nil
+
+	collected := false
+	for i := 0; i < 10 && !collected; i++ {
+		runtime.GC()
+		select {
+		case <-released:
+			collected = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	assert.NoError(t, err)
+	assert.True(t, collected)
+}
+
+func TestQuarryImpl_Child_cycleThroughParent(t *testing.T) {
+	q := quarry.New()
+	q.MustAddDependency("a", "b")
+	q.MustAddDependency("b", "c")
+	child := q.Child()
+
+	err := child.AddDependency("c", "a")
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.Equal(t, []string{"c", "a", "b", "c"}, cycleErr.Path)
+}
+
+func TestQuarryImpl_Child_cycleCompletedByParent(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddFactory("b", factoryOk())
+	q.MustAddFactory("c", factoryOk())
+	q.MustAddDependency("a", "b")
+	child := q.Child()
+	child.MustAddDependency("c", "a")
+	q.MustAddDependency("b", "c")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	validateErr := child.Validate()
+	_, getErr := child.Get(ctx, nil, "a")
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(validateErr, &cycleErr))
+	assert.Equal(t, []string{"a", "b", "c", "a"}, cycleErr.Path)
+	assert.True(t, errors.As(getErr, &cycleErr))
+	assert.Equal(t, []string{"c", "a", "b", "c"}, cycleErr.Path)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestQuarryImpl_Child_aliasCycleCompletedByParent(t *testing.T) {
+	q := quarry.New()
+	child := q.Child()
+	child.MustAddAlias("y", "x")
+	q.MustAddAlias("x", "y")
+
+	_, err := child.Get(context.Background(), nil, "x")
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.Equal(t, []string{"x", "y", "x"}, cycleErr.Path)
+}
+
+func TestQuarryImpl_Child_overrideBreaksParentCycle(t *testing.T) {
+	q := quarry.New()
+	q.MustAddDependency("a", "b")
+	child := q.Child()
+	child.MustAddFactory("a", factoryOk())
+
+	err := child.AddDependency("b", "a")
+
+	assert.NoError(t, err)
+}
+
+func TestQuarryImpl_Child_validate(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("secret", factoryOk())
+	q.MustAddDependency("root", "secret")
+	child := q.Child()
+	child.MustAddFactory("secret", factoryOk())
+	child.MustAddDependency("secret", "vault")
+
+	childErr := child.Validate()
+	parentErr := q.Validate()
+
+	assert.EqualError(t, childErr, "invalid quarry: factory vault, depended upon by secret, does not exist; "+
+		"factory root can never be resolved because it transitively depends on missing factory vault")
+	assert.NoError(t, parentErr)
+}
+
+func TestQuarryImpl_Child_constructorFindsParentTypes(t *testing.T) {
+	q := quarry.New()
+	quarry.MustAddTypedFactory(q, quarry.NewKey[string]("greeting"), func(ctx context.Context, params interface{}, deps quarry.Dependencies) (string, error) {
+		return "hello", nil
+	})
+	child := q.Child()
+
+	err := child.AddConstructor("length", func(greeting string) int { return len(greeting) })
+	value, getErr := child.Get(context.Background(), nil, "length")
+
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, 5, value)
+}
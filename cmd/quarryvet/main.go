@@ -0,0 +1,17 @@
+// Command quarryvet checks how the nodes of a Quarry are wired together.
+// It is run with go vet:
+//
+//	go install github.com/explodes/quarry/cmd/quarryvet
+//	go vet -vettool=$(which quarryvet) ./...
+//
+// See package quarryvet for the checks it makes.
+package main
+
+import (
+	"github.com/explodes/quarry/quarryvet"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(quarryvet.Analyzer)
+}
@@ -1,42 +1,182 @@
 package quarry
 
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Predicate decides whether a dependency should be fulfilled.
+// Condition and ContextCondition are Predicates, and Predicates can be
+// combined with And, Or and Not, and named with Named or NamedCondition.
+type Predicate interface {
+	// Evaluate returns true if the dependency should be fulfilled.
+	Evaluate(ctx context.Context, params interface{}) bool
+	// String describes the Predicate, such as in the reason a dependency was skipped.
+	String() string
+}
+
 // Condition defines when a dependency should be fulfilled. By default, dependencies
 // are always fulfilled, but when conditions are present they all must be met before
 // fulfilling a dependency.
 // Dependencies that do not meet their required conditions are filled as nil.
 type Condition func(params interface{}) bool
 
-type conditionMap map[string][]Condition
+func (c Condition) Evaluate(ctx context.Context, params interface{}) bool {
+	return c(params)
+}
 
-func newConditionMap() conditionMap {
-	return make(conditionMap)
+func (c Condition) String() string {
+	return "condition"
 }
 
-func (c conditionMap) Size() int {
-	return len(c)
+// ContextCondition is a Condition that also receives the context of the
+// resolution, such as to read feature flags or authentication from the request.
+type ContextCondition func(ctx context.Context, params interface{}) bool
+
+func (c ContextCondition) Evaluate(ctx context.Context, params interface{}) bool {
+	return c(ctx, params)
 }
 
-func (c conditionMap) Add(value string, conditions ...Condition) {
-	c[value] = conditions
+func (c ContextCondition) String() string {
+	return "contextCondition"
 }
 
-func (c conditionMap) Contains(val string) bool {
-	_, ok := c[val]
-	return ok
+// DataCondition defines when a dependency should be fulfilled based on the
+// values of prerequisite nodes, such as fetching an admin dashboard only when
+// the resolved user is an admin. See WhenResolved.
+type DataCondition func(ctx context.Context, params interface{}, prerequisites Dependencies) bool
+
+// guard is a DataCondition and the prerequisites it is checked against.
+type guard struct {
+	condition     DataCondition
+	prerequisites []string
+	// keys are the keys the prerequisites are delivered under, in the same
+	// order, when they differ from the names of the prerequisites.
+	keys []string
 }
 
-// checkConditions ensures that all conditions are met.
-// If there are no conditions, the conditions are considered to be met.
-func checkConditions(params interface{}, conditions []Condition) bool {
-	// Quickest path out.
-	if len(conditions) == 0 {
-		return true
+// key returns the key the i-th prerequisite is delivered under.
+func (g *guard) key(i int) string {
+	if g.keys != nil {
+		return g.keys[i]
 	}
-	// If any condition fails, the check fails.
-	for _, condition := range conditions {
-		if !condition(params) {
+	return g.prerequisites[i]
+}
+
+func (g *guard) String() string {
+	keys := g.keys
+	if keys == nil {
+		keys = g.prerequisites
+	}
+	return fmt.Sprintf("condition on %s", strings.Join(keys, ", "))
+}
+
+// Named gives a Predicate a name to describe it by.
+func Named(name string, predicate Predicate) Predicate {
+	return namedPredicate{name: name, predicate: predicate}
+}
+
+// NamedCondition gives a Condition a name to describe it by, such as "showUnread".
+func NamedCondition(name string, condition Condition) Predicate {
+	return Named(name, condition)
+}
+
+type namedPredicate struct {
+	name      string
+	predicate Predicate
+}
+
+func (p namedPredicate) Evaluate(ctx context.Context, params interface{}) bool {
+	return p.predicate.Evaluate(ctx, params)
+}
+
+func (p namedPredicate) String() string {
+	return p.name
+}
+
+// And is met when all predicates are met. Evaluation stops at the first
+// predicate that is not met.
+func And(predicates ...Predicate) Predicate {
+	return andPredicate(predicates)
+}
+
+type andPredicate []Predicate
+
+func (p andPredicate) Evaluate(ctx context.Context, params interface{}) bool {
+	for _, predicate := range p {
+		if !predicate.Evaluate(ctx, params) {
 			return false
 		}
 	}
 	return true
 }
+
+func (p andPredicate) String() string {
+	return joinPredicates(p, " && ")
+}
+
+// Or is met when any predicate is met. Evaluation stops at the first
+// predicate that is met.
+func Or(predicates ...Predicate) Predicate {
+	return orPredicate(predicates)
+}
+
+type orPredicate []Predicate
+
+func (p orPredicate) Evaluate(ctx context.Context, params interface{}) bool {
+	for _, predicate := range p {
+		if predicate.Evaluate(ctx, params) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p orPredicate) String() string {
+	return joinPredicates(p, " || ")
+}
+
+// Not is met when predicate is not met.
+func Not(predicate Predicate) Predicate {
+	return notPredicate{predicate: predicate}
+}
+
+type notPredicate struct {
+	predicate Predicate
+}
+
+func (p notPredicate) Evaluate(ctx context.Context, params interface{}) bool {
+	return !p.predicate.Evaluate(ctx, params)
+}
+
+func (p notPredicate) String() string {
+	return fmt.Sprintf("!%s", p.predicate)
+}
+
+// joinPredicates describes predicates joined by an operator.
+func joinPredicates(predicates []Predicate, op string) string {
+	descriptions := make([]string, len(predicates))
+	for i, predicate := range predicates {
+		descriptions[i] = predicate.String()
+	}
+	return fmt.Sprintf("(%s)", strings.Join(descriptions, op))
+}
+
+// checkConditions ensures that all conditions are met, returning the first
+// one that is not, or nil if they all are.
+// If there are no conditions, the conditions are considered to be met.
+func checkConditions(ctx context.Context, params interface{}, conditions []Predicate) Predicate {
+	// If any condition fails, the check fails.
+	for _, condition := range conditions {
+		if !condition.Evaluate(ctx, params) {
+			return condition
+		}
+	}
+	return nil
+}
+
+// skipReason describes why a dependency was skipped because of an unmet condition.
+func skipReason(unmet fmt.Stringer) string {
+	return fmt.Sprintf("%s=false", unmet)
+}
@@ -0,0 +1,200 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+type flagKey struct{}
+
+var (
+	yes = quarry.NamedCondition("yes", func(interface{}) bool { return true })
+	no  = quarry.NamedCondition("no", func(interface{}) bool { return false })
+)
+
+func TestCombinators(t *testing.T) {
+	tests := []struct {
+		predicate   quarry.Predicate
+		met         bool
+		description string
+	}{
+		{quarry.And(yes, yes), true, "(yes && yes)"},
+		{quarry.And(yes, no), false, "(yes && no)"},
+		{quarry.And(), true, "()"},
+		{quarry.Or(no, yes), true, "(no || yes)"},
+		{quarry.Or(no, no), false, "(no || no)"},
+		{quarry.Or(), false, "()"},
+		{quarry.Not(no), true, "!no"},
+		{quarry.Not(quarry.And(yes, no)), true, "!(yes && no)"},
+		{quarry.Named("both", quarry.And(yes, yes)), true, "both"},
+		{quarry.Condition(func(interface{}) bool { return true }), true, "condition"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.met, test.predicate.Evaluate(context.Background(), nil))
+			assert.Equal(t, test.description, test.predicate.String())
+		})
+	}
+}
+
+func TestContextCondition(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddFactory("feature", factoryValue("on"))
+	flag := quarry.ContextCondition(func(ctx context.Context, params interface{}) bool {
+		enabled, _ := ctx.Value(flagKey{}).(bool)
+		return enabled
+	})
+	q.MustAddEdge("root", "feature", quarry.WhenAll(flag))
+
+	enabled, enabledErr := q.Get(context.WithValue(context.Background(), flagKey{}, true), nil, "root")
+	disabled, disabledErr := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, enabledErr)
+	assert.NoError(t, disabledErr)
+	assert.Equal(t, quarry.Dependencies{"feature": "on"}, enabled)
+	assert.Equal(t, quarry.Dependencies{"feature": nil}, disabled)
+}
+
+func TestSkipReason(t *testing.T) {
+	q := quarry.New()
+	var reason, resolvedReason string
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		reason, resolvedReason = quarry.SkipReason(ctx, "unread"), quarry.SkipReason(ctx, "all")
+		return nil, nil
+	})
+	q.MustAddFactory("unread", factoryOk())
+	q.MustAddFactory("all", factoryOk())
+	showUnread := quarry.NamedCondition("showUnread", func(params interface{}) bool { return params.(bool) })
+	q.MustAddEdge("root", "unread", quarry.WhenAll(yes, showUnread))
+	q.MustAddEdge("root", "all", quarry.WhenAll(yes))
+
+	_, err := q.Get(context.Background(), false, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "showUnread=false", reason)
+	assert.Equal(t, "", resolvedReason)
+}
+
+func TestWhenAll_combinesWithWhen(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddFactory("dep", factoryOk())
+	q.MustAddEdge("root", "dep", quarry.When(func(interface{}) bool { return true }), quarry.WhenAll(no))
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"dep": nil}, value)
+}
+
+// isAdmin checks whether the resolved user is an admin.
+func isAdmin(ctx context.Context, params interface{}, prerequisites quarry.Dependencies) bool {
+	return prerequisites["user"] == "admin"
+}
+
+func TestWhenResolved(t *testing.T) {
+	for _, user := range []string{"admin", "guest"} {
+		t.Run(user, func(t *testing.T) {
+			q := quarry.New()
+			count, counter := factoryCounter()
+			q.MustAddFactory("root", factoryDeps())
+			q.MustAddFactory("user", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+				_, _ = counter(ctx, params, deps)
+				return params, nil
+			})
+			dashboardCount, dashboard := factoryCounter()
+			q.MustAddFactory("dashboard", dashboard)
+			q.MustAddDependency("root", "user")
+			q.MustAddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"))
+
+			value, err := q.Get(context.Background(), user, "root")
+
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), *count)
+			if user == "admin" {
+				assert.Equal(t, quarry.Dependencies{"user": "admin", "dashboard": int32(1)}, value)
+				assert.Equal(t, int32(1), *dashboardCount)
+			} else {
+				assert.Equal(t, quarry.Dependencies{"user": "guest", "dashboard": nil}, value)
+				assert.Equal(t, int32(0), *dashboardCount)
+			}
+		})
+	}
+}
+
+func TestWhenResolved_skipReason(t *testing.T) {
+	q := quarry.New()
+	var reason string
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		reason = quarry.SkipReason(ctx, "dashboard")
+		return nil, nil
+	})
+	q.MustAddFactory("user", factoryValue("guest"))
+	q.MustAddFactory("dashboard", factoryOk())
+	q.MustAddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "condition on user=false", reason)
+}
+
+func TestWhenResolved_prerequisiteFailureFailsResolution(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("user", factoryError())
+	q.MustAddFactory("dashboard", factoryOk())
+	q.MustAddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, "user", resolutionErr.Node)
+	assert.Equal(t, quarry.FactoryFailed, resolutionErr.Kind)
+}
+
+func TestWhenResolved_optionalPrerequisiteFailure(t *testing.T) {
+	q := quarry.New()
+	var failed bool
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		failed = quarry.Failed(ctx, "dashboard")
+		return nil, nil
+	})
+	q.MustAddFactory("user", factoryError())
+	q.MustAddFactory("dashboard", factoryOk())
+	q.MustAddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"), quarry.Optional())
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.True(t, failed)
+}
+
+func TestWhenResolved_prerequisiteCycle(t *testing.T) {
+	q := quarry.New()
+	q.MustAddDependency("user", "root")
+
+	err := q.AddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"))
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.Equal(t, []string{"root", "user", "root"}, cycleErr.Path)
+	assert.NoError(t, q.AddDependency("dashboard", "root"))
+}
+
+func TestWhenResolved_validatesPrerequisites(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("dashboard", factoryOk())
+	q.MustAddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"))
+
+	err := q.Validate()
+
+	assert.EqualError(t, err, "invalid quarry: factory user, depended upon by root, does not exist")
+}
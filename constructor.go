@@ -0,0 +1,208 @@
+package quarry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// constructor is a function whose parameters are dependencies.
+type constructor struct {
+	fn reflect.Value
+	// args holds, for each parameter of fn, the name of the dependency that fills it.
+	// Context parameters are represented by an empty name.
+	args []string
+	// returnsErr is true when fn returns an error as its second result.
+	returnsErr bool
+}
+
+// newConstructor validates fn and pairs its dependency parameters with names.
+// When names is empty, the dependency names are found using lookup.
+func newConstructor(fn interface{}, names []string, lookup func(reflect.Type) (string, error)) (*constructor, error) {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func {
+		return nil, fmt.Errorf("constructor must be a function, got %T", fn)
+	}
+	fnType := value.Type()
+	if fnType.IsVariadic() {
+		return nil, fmt.Errorf("constructor %s must not be variadic", fnType)
+	}
+	switch {
+	case fnType.NumOut() == 1:
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+	default:
+		return nil, fmt.Errorf("constructor %s must return a value and an optional error", fnType)
+	}
+
+	c := &constructor{
+		fn:         value,
+		args:       make([]string, fnType.NumIn()),
+		returnsErr: fnType.NumOut() == 2,
+	}
+	var numDeps int
+	for i := 0; i < fnType.NumIn(); i++ {
+		if fnType.In(i) != contextType {
+			numDeps++
+		}
+	}
+	if len(names) != 0 && len(names) != numDeps {
+		return nil, fmt.Errorf("constructor %s has %d dependencies but %d names were given", fnType, numDeps, len(names))
+	}
+	dep := 0
+	for i := 0; i < fnType.NumIn(); i++ {
+		in := fnType.In(i)
+		if in == contextType {
+			continue
+		}
+		if len(names) != 0 {
+			c.args[i] = names[dep]
+		} else {
+			name, err := lookup(in)
+			if err != nil {
+				return nil, err
+			}
+			c.args[i] = name
+		}
+		dep++
+	}
+	return c, nil
+}
+
+// resultType is the type of value produced by the constructor.
+func (c *constructor) resultType() reflect.Type {
+	return c.fn.Type().Out(0)
+}
+
+// dependencies returns the distinct names of the constructor's dependencies.
+func (c *constructor) dependencies() []string {
+	seen := newStringSet()
+	var names []string
+	for _, name := range c.args {
+		if name == "" || seen.Contains(name) {
+			continue
+		}
+		seen.Add(name)
+		names = append(names, name)
+	}
+	return names
+}
+
+// factory creates a Factory that calls the constructor with its dependencies.
+func (c *constructor) factory() Factory {
+	fnType := c.fn.Type()
+	return func(ctx context.Context, params interface{}, deps Dependencies) (interface{}, error) {
+		in := make([]reflect.Value, len(c.args))
+		for i, name := range c.args {
+			argType := fnType.In(i)
+			if name == "" {
+				if ctx == nil {
+					in[i] = reflect.Zero(argType)
+				} else {
+					in[i] = reflect.ValueOf(ctx)
+				}
+				continue
+			}
+			dep := deps[name]
+			if dep == nil {
+				in[i] = reflect.Zero(argType)
+				continue
+			}
+			value := reflect.ValueOf(dep)
+			if !value.Type().AssignableTo(argType) {
+				return nil, fmt.Errorf("dependency %s is %T, want %s", name, dep, argType)
+			}
+			in[i] = value
+		}
+		out := c.fn.Call(in)
+		if c.returnsErr && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	}
+}
+
+func (q *quarryImpl) MustAddConstructor(name string, fn interface{}, dependsOn ...string) {
+	if err := q.AddConstructor(name, fn, dependsOn...); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) AddConstructor(name string, fn interface{}, dependsOn ...string) error {
+	c, err := newConstructor(fn, dependsOn, q.nameForType)
+	if err != nil {
+		return fmt.Errorf("invalid constructor for %s: %v", name, err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.addFactory(name, c.factory(), nil); err != nil {
+		return err
+	}
+	_, declared := q.adjacency[name]
+	var added, linked []string
+	for _, dep := range c.dependencies() {
+		wasLinked := q.successors[name].Contains(dep)
+		if err := q.addEdge(name, dep, nil); err != nil {
+			// Leave the quarry as it was before the constructor was added.
+			for _, dep := range added {
+				q.adjacency[name].Remove(dep)
+			}
+			if !declared {
+				delete(q.adjacency, name)
+			}
+			for _, dep := range linked {
+				q.unlink(name, dep)
+			}
+			delete(q.factories, name)
+			return err
+		}
+		added = append(added, dep)
+		if !wasLinked {
+			linked = append(linked, dep)
+		}
+	}
+	q.types[name] = c.resultType()
+	return nil
+}
+
+// setType records the type of value produced by the named factory.
+func (q *quarryImpl) setType(name string, t reflect.Type) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.types[name] = t
+}
+
+// nameForType finds the single factory that produces values of the given type.
+// Factories producing exactly the type are preferred over those producing a
+// type that is merely assignable to it.
+func (q *quarryImpl) nameForType(t reflect.Type) (string, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var exact, assignable []string
+	for name, produced := range q.allTypes() {
+		switch {
+		case produced == t:
+			exact = append(exact, name)
+		case produced.AssignableTo(t):
+			assignable = append(assignable, name)
+		}
+	}
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = assignable
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no factory produces %s", t)
+	case 1:
+		return candidates[0], nil
+	default:
+		sort.Strings(candidates)
+		return "", fmt.Errorf("multiple factories produce %s: %v", t, candidates)
+	}
+}
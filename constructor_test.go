@@ -0,0 +1,127 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+type greeter interface {
+	Greet() string
+}
+
+type englishGreeter struct {
+	name string
+}
+
+func (g *englishGreeter) Greet() string {
+	return fmt.Sprintf("hello %s", g.name)
+}
+
+func TestQuarryImpl_AddConstructor_namedDependencies(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("name", quarry.Provider("world"))
+	q.MustAddConstructor("greeting", func(ctx context.Context, name string) string {
+		return "hello " + name
+	}, "name")
+
+	value, err := q.Get(context.Background(), nil, "greeting")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", value)
+}
+
+func TestQuarryImpl_AddConstructor_dependenciesByType(t *testing.T) {
+	q := quarry.New()
+	quarry.MustAddTypedFactory(q, quarry.NewKey[string]("name"), func(ctx context.Context, params interface{}, deps quarry.Dependencies) (string, error) {
+		return "world", nil
+	})
+	q.MustAddConstructor("greeter", func(name string) (*englishGreeter, error) {
+		return &englishGreeter{name: name}, nil
+	})
+	q.MustAddConstructor("greeting", func(ctx context.Context, g greeter) string {
+		return g.Greet()
+	})
+
+	value, err := q.Get(context.Background(), nil, "greeting")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", value)
+}
+
+func TestQuarryImpl_AddConstructor_returnsError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddConstructor("failing", func() (string, error) {
+		return "", errors.New("some-error")
+	})
+
+	_, err := q.Get(context.Background(), nil, "failing")
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddConstructor_unknownTypeResultsInError(t *testing.T) {
+	q := quarry.New()
+
+	err := q.AddConstructor("greeting", func(g greeter) string {
+		return g.Greet()
+	})
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddConstructor_ambiguousTypeResultsInError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddConstructor("a", func() string { return "a" })
+	q.MustAddConstructor("b", func() string { return "b" })
+
+	err := q.AddConstructor("c", func(s string) string { return s })
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddConstructor_wrongNameCountResultsInError(t *testing.T) {
+	q := quarry.New()
+
+	err := q.AddConstructor("greeting", func(a, b string) string { return a + b }, "a")
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddConstructor_notAFunctionResultsInError(t *testing.T) {
+	q := quarry.New()
+
+	err := q.AddConstructor("greeting", "hello")
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddConstructor_dependencyTypeMismatchResultsInError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("name", quarry.Provider(1))
+	q.MustAddConstructor("greeting", func(name string) string { return name }, "name")
+
+	_, err := q.Get(context.Background(), nil, "greeting")
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddConstructor_cycleLeavesQuarryUnchanged(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("name", quarry.Provider("world"))
+	q.MustAddFactory("title", factoryOk())
+	q.MustAddDependency("title", "greeting")
+
+	err := q.AddConstructor("greeting", func(name, title string) string { return name }, "name", "title")
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.False(t, q.Has("greeting"))
+	assert.Empty(t, q.DependenciesOf("greeting"))
+	assert.Empty(t, q.DependentsOf("name"))
+	assert.NoError(t, q.AddDependency("name", "greeting"))
+}
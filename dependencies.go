@@ -0,0 +1,154 @@
+package quarry
+
+import (
+	"context"
+	"fmt"
+)
+
+// Dependencies is a map of named values that are provided to Factories.
+type Dependencies map[string]interface{}
+
+// Contains returns true when these dependencies has the given key.
+func (d Dependencies) Contains(key string) bool {
+	_, ok := d[key]
+	return ok
+}
+
+// DependencyStatus describes how a dependency was fulfilled.
+type DependencyStatus int
+
+const (
+	// StatusUnknown means the key is not a dependency.
+	StatusUnknown DependencyStatus = iota
+	// StatusResolved means the dependency's Factory created its value.
+	StatusResolved
+	// StatusSkipped means the dependency's conditions were not met.
+	// Its value is nil.
+	StatusSkipped
+	// StatusFailed means the dependency's Factory is missing or failed, but the
+	// dependency was optional or was delivered as nil on timeout.
+	StatusFailed
+)
+
+func (s DependencyStatus) String() string {
+	switch s {
+	case StatusUnknown:
+		return "unknown"
+	case StatusResolved:
+		return "resolved"
+	case StatusSkipped:
+		return "skipped"
+	case StatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("DependencyStatus(%d)", int(s))
+	}
+}
+
+// Status returns how the dependency delivered under key to a Factory was
+// fulfilled. ctx must be the Context the Factory was called with; for any
+// other Context, and for keys that are not dependencies of the Factory, it
+// returns StatusUnknown.
+func Status(ctx context.Context, key string) DependencyStatus {
+	if info := dependencyInfoFrom(ctx); info != nil {
+		return info.statuses[key]
+	}
+	return StatusUnknown
+}
+
+// Resolved returns true if the dependency's Factory created its value.
+// See Status for ctx.
+func Resolved(ctx context.Context, key string) bool {
+	return Status(ctx, key) == StatusResolved
+}
+
+// Skipped returns true if the dependency was not fulfilled because its
+// conditions were not met. Unlike checking for a nil value, this tells a
+// skipped dependency from a Factory that returned nil.
+// See Status for ctx.
+func Skipped(ctx context.Context, key string) bool {
+	return Status(ctx, key) == StatusSkipped
+}
+
+// SkipReason describes the condition that was not met for a skipped
+// dependency, such as "showUnread=false", or returns an empty string if the
+// dependency was not skipped.
+// See Status for ctx.
+func SkipReason(ctx context.Context, key string) string {
+	if info := dependencyInfoFrom(ctx); info != nil {
+		return info.reasons[key]
+	}
+	return ""
+}
+
+// Failed returns true if the dependency's Factory is missing or failed without
+// failing the resolution. DependencyErr returns the reason.
+// See Status for ctx.
+func Failed(ctx context.Context, key string) bool {
+	return Status(ctx, key) == StatusFailed
+}
+
+// DependencyErr returns the error of a dependency that failed without failing
+// the resolution, such as an optional dependency that was left out because
+// its Factory is missing or failed, or nil otherwise.
+// See Status for ctx.
+func DependencyErr(ctx context.Context, key string) error {
+	if info := dependencyInfoFrom(ctx); info != nil {
+		return info.errs[key]
+	}
+	return nil
+}
+
+// dependencyInfo is what is known about the Dependencies of a Factory beyond
+// their values.
+type dependencyInfo struct {
+	// statuses holds the status of every dependency.
+	statuses map[string]DependencyStatus
+	// reasons holds why skipped dependencies were skipped.
+	reasons map[string]string
+	// errs holds the errors of dependencies that failed.
+	errs map[string]error
+}
+
+func newDependencyInfo() *dependencyInfo {
+	return &dependencyInfo{
+		statuses: make(map[string]DependencyStatus),
+		reasons:  make(map[string]string),
+		errs:     make(map[string]error),
+	}
+}
+
+// resolve records that a dependency was resolved.
+func (i *dependencyInfo) resolve(key string) {
+	i.statuses[key] = StatusResolved
+}
+
+// skip records that a dependency was skipped and why.
+func (i *dependencyInfo) skip(key, reason string) {
+	i.statuses[key] = StatusSkipped
+	i.reasons[key] = reason
+}
+
+// fail records that a dependency failed without failing the resolution.
+func (i *dependencyInfo) fail(key string, err error) {
+	i.statuses[key] = StatusFailed
+	i.errs[key] = err
+}
+
+// dependencyInfoKey is the Context key of the dependencyInfo of a Factory.
+type dependencyInfoKey struct{}
+
+// withDependencyInfo returns a Context for a Factory whose Dependencies are
+// described by info.
+func withDependencyInfo(ctx context.Context, info *dependencyInfo) context.Context {
+	return context.WithValue(ctx, dependencyInfoKey{}, info)
+}
+
+// dependencyInfoFrom returns the dependencyInfo of a Factory, or nil.
+func dependencyInfoFrom(ctx context.Context) *dependencyInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(dependencyInfoKey{}).(*dependencyInfo)
+	return info
+}
@@ -0,0 +1,142 @@
+package quarry
+
+import "sort"
+
+// EdgeOption configures how a Factory depends on another.
+type EdgeOption func(e *edge)
+
+// When makes a dependency conditional: it is only fulfilled when all
+// conditions are met, and is otherwise filled as nil.
+func When(conditions ...Condition) EdgeOption {
+	return func(e *edge) {
+		for _, condition := range conditions {
+			e.conditions = append(e.conditions, condition)
+		}
+	}
+}
+
+// WhenAll makes a dependency conditional like When, using Predicates such as
+// ContextConditions, named conditions and combinations of them.
+func WhenAll(predicates ...Predicate) EdgeOption {
+	return func(e *edge) {
+		e.conditions = append(e.conditions, predicates...)
+	}
+}
+
+// WhenResolved makes a dependency conditional on the values of other nodes:
+// the prerequisites are resolved first, within the same resolution, and the
+// dependency is only fulfilled when condition is met given their values.
+// It is otherwise filled as nil.
+func WhenResolved(condition DataCondition, prerequisites ...string) EdgeOption {
+	return func(e *edge) {
+		e.guards = append(e.guards, &guard{condition: condition, prerequisites: prerequisites})
+	}
+}
+
+// Optional makes a dependency tolerate a missing or failing Factory.
+// Instead of failing the resolution, such a dependency is left out of the
+// Dependencies, and the error is available from Dependencies.Err.
+func Optional() EdgeOption {
+	return func(e *edge) {
+		e.optional = true
+	}
+}
+
+// As delivers a dependency to the parent Factory under key instead of the
+// name of the node, so that a reusable Factory can read a fixed key such as
+// deps["db"] while being wired to primaryDB or replicaDB.
+func As(key string) EdgeOption {
+	return func(e *edge) {
+		e.as = key
+	}
+}
+
+// edge describes how a Factory depends on another.
+type edge struct {
+	// conditions must all be met for the dependency to be fulfilled.
+	conditions []Predicate
+	// guards must all be met, after resolving their prerequisites, for the
+	// dependency to be fulfilled.
+	guards []*guard
+	// optional dependencies do not fail the resolution when they fail.
+	optional bool
+	// as is the key the dependency is delivered under, if not its name.
+	as string
+}
+
+func newEdge(options []EdgeOption) *edge {
+	e := &edge{}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// key returns the key the dependency on dependsOn is delivered under.
+func (e *edge) key(dependsOn string) string {
+	if e.as != "" {
+		return e.as
+	}
+	return dependsOn
+}
+
+// conditional returns true if the dependency may be skipped.
+func (e *edge) conditional() bool {
+	return len(e.conditions) > 0 || len(e.guards) > 0
+}
+
+// requires returns the names of the nodes the parent may need to resolve to
+// fulfill the dependency on dependsOn, beginning with dependsOn.
+func (e *edge) requires(dependsOn string) []string {
+	names := []string{dependsOn}
+	for _, g := range e.guards {
+		names = append(names, g.prerequisites...)
+	}
+	return names
+}
+
+// edgeMap is a map of names of Factories to how they are depended upon.
+type edgeMap map[string]*edge
+
+func newEdgeMap() edgeMap {
+	return make(edgeMap)
+}
+
+// clone returns a shallow copy of the map, or nil if the map is nil.
+func (m edgeMap) clone() edgeMap {
+	if m == nil {
+		return nil
+	}
+	clone := make(edgeMap, len(m))
+	for name, e := range m {
+		clone[name] = e
+	}
+	return clone
+}
+
+func (m edgeMap) Size() int {
+	return len(m)
+}
+
+func (m edgeMap) Add(name string, e *edge) {
+	m[name] = e
+}
+
+func (m edgeMap) Remove(name string) {
+	delete(m, name)
+}
+
+func (m edgeMap) Contains(name string) bool {
+	_, ok := m[name]
+	return ok
+}
+
+// sortedKeys returns the names in the map in sorted order.
+func (m edgeMap) sortedKeys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,219 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptional_missingFactoryIsAbsent(t *testing.T) {
+	q := quarry.New()
+	var depErr error
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		depErr = quarry.DependencyErr(ctx, "cache")
+		return deps.Contains("cache"), nil
+	})
+	q.MustAddEdge("root", "cache", quarry.Optional())
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, false, value)
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(depErr, &resolutionErr))
+	assert.Equal(t, quarry.FactoryMissing, resolutionErr.Kind)
+}
+
+func TestOptional_failingFactoryIsAbsent(t *testing.T) {
+	someErr := errors.New("some-error")
+	q := quarry.New()
+	var depErr error
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		depErr = quarry.DependencyErr(ctx, "cache")
+		return deps.Contains("cache"), nil
+	})
+	q.MustAddFactory("cache", factoryErr(someErr))
+	q.MustAddEdge("root", "cache", quarry.Optional())
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, false, value)
+	assert.True(t, errors.Is(depErr, someErr))
+}
+
+func TestOptional_transitiveFailureDoesNotCancelResolution(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryWithDeps(factoryOk(), "slow"))
+	q.MustAddFactory("slow", factorySlow(20*time.Millisecond))
+	q.MustAddFactory("cache", factoryOk())
+	q.MustAddFactory("cacheBackend", factoryError())
+	q.MustAddDependency("root", "slow")
+	q.MustAddEdge("root", "cache", quarry.Optional())
+	q.MustAddDependency("cache", "cacheBackend")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+}
+
+func TestOptional_resolvedIsPresent(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryWithDeps(factoryOk(), "cache"))
+	q.MustAddFactory("cache", factoryOk())
+	q.MustAddEdge("root", "cache", quarry.Optional())
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+}
+
+func TestOptional_requiredSiblingStillFails(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("a", factoryError())
+	q.MustAddEdge("root", "cache", quarry.Optional())
+	q.MustAddDependency("root", "a")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.Error(t, err)
+}
+
+func TestOptional_sharedWithRequiredDependencyFails(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddFactory("shared", factoryError())
+	q.MustAddEdge("root", "shared", quarry.Optional())
+	q.MustAddDependency("root", "a")
+	q.MustAddDependency("a", "shared")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.Error(t, err)
+}
+
+func TestOptional_isNotReportedByValidate(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddEdge("root", "cache", quarry.Optional())
+
+	err := q.Validate()
+
+	assert.NoError(t, err)
+}
+
+func TestWhen_isConditional(t *testing.T) {
+	q := quarry.New()
+	count, counter := factoryCounter()
+	q.MustAddFactory("root", factoryWithNonNilDeps(counter))
+	q.MustAddFactory("a", counter)
+	q.MustAddEdge("root", "a", quarry.When(func(params interface{}) bool {
+		return params.(bool)
+	}))
+
+	_, err := q.Get(context.Background(), false, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *count)
+}
+
+func TestStatus_outsideFactory(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, quarry.StatusUnknown, quarry.Status(ctx, "a"))
+	assert.NoError(t, quarry.DependencyErr(ctx, "a"))
+	assert.Equal(t, "", quarry.SkipReason(ctx, "a"))
+}
+
+func TestStatus(t *testing.T) {
+	q := quarry.New()
+	statuses := make(map[string]quarry.DependencyStatus)
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		for _, name := range []string{"resolved", "nil", "skipped", "failed", "unknown"} {
+			statuses[name] = quarry.Status(ctx, name)
+		}
+		return nil, nil
+	})
+	q.MustAddFactory("resolved", factoryOk())
+	q.MustAddFactory("nil", quarry.Provider(nil))
+	q.MustAddFactory("skipped", factoryOk())
+	q.MustAddFactory("failed", factoryError())
+	q.MustAddDependency("root", "resolved")
+	q.MustAddDependency("root", "nil")
+	q.MustAddDependency("root", "skipped", func(interface{}) bool { return false })
+	q.MustAddEdge("root", "failed", quarry.Optional())
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]quarry.DependencyStatus{
+		"resolved": quarry.StatusResolved,
+		"nil":      quarry.StatusResolved,
+		"skipped":  quarry.StatusSkipped,
+		"failed":   quarry.StatusFailed,
+		"unknown":  quarry.StatusUnknown,
+	}, statuses)
+}
+
+func TestSkipped_distinguishesNil(t *testing.T) {
+	q := quarry.New()
+	var skipped, nilSkipped bool
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		skipped, nilSkipped = quarry.Skipped(ctx, "skipped"), quarry.Skipped(ctx, "nil")
+		return deps, nil
+	})
+	q.MustAddFactory("nil", quarry.Provider(nil))
+	q.MustAddFactory("skipped", factoryOk())
+	q.MustAddDependency("root", "nil")
+	q.MustAddDependency("root", "skipped", func(interface{}) bool { return false })
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.True(t, skipped)
+	assert.False(t, nilSkipped)
+	assert.Equal(t, quarry.Dependencies{"nil": nil, "skipped": nil}, value)
+}
+
+func TestStatus_availableToFallback(t *testing.T) {
+	q := quarry.New()
+	var skipReason string
+	var failedErr error
+	q.MustAddFactory("root", factoryError())
+	q.MustAddFallback("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		skipReason, failedErr = quarry.SkipReason(ctx, "skipped"), quarry.DependencyErr(ctx, "failed")
+		return nil, nil
+	})
+	q.MustAddFactory("skipped", factoryOk())
+	q.MustAddFactory("failed", factoryErr(errors.New("down")))
+	q.MustAddEdge("root", "skipped", quarry.WhenAll(quarry.NamedCondition("showSkipped", func(interface{}) bool { return false })))
+	q.MustAddEdge("root", "failed", quarry.Optional())
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "showSkipped=false", skipReason)
+	assert.EqualError(t, errors.Unwrap(failedErr), "down")
+}
+
+func TestNilOnTimeout_statusIsFailed(t *testing.T) {
+	q := quarry.New()
+	var failed bool
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		failed = quarry.Failed(ctx, "slow")
+		return nil, nil
+	})
+	q.MustAddFactory("slow", factorySlow(time.Second), quarry.WithTimeout(10*time.Millisecond), quarry.NilOnTimeout())
+	q.MustAddDependency("root", "slow", func(interface{}) bool { return true })
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.True(t, failed)
+}
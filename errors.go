@@ -0,0 +1,148 @@
+package quarry
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// CycleError is returned when adding a dependency would create a cycle.
+type CycleError struct {
+	// Parent and DependsOn name the rejected dependency.
+	Parent, DependsOn string
+	// Path holds the names of the nodes forming the cycle, in dependency order,
+	// beginning and ending with Parent.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("depending %s on %s creates a cycle: %s", e.Parent, e.DependsOn, strings.Join(e.Path, " -> "))
+}
+
+// ErrorKind describes why a resolution failed.
+type ErrorKind int
+
+const (
+	// FactoryFailed means a Factory returned an error.
+	FactoryFailed ErrorKind = iota
+	// FactoryMissing means no Factory is registered for a name.
+	FactoryMissing
+	// ContextDone means the Context was canceled or its deadline passed.
+	ContextDone
+	// FactoryTimedOut means a Factory exceeded the timeout it was registered with.
+	FactoryTimedOut
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case FactoryFailed:
+		return "factory failed"
+	case FactoryMissing:
+		return "factory missing"
+	case ContextDone:
+		return "context done"
+	case FactoryTimedOut:
+		return "factory timed out"
+	default:
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+}
+
+// ResolutionError is returned when fetching an object fails.
+type ResolutionError struct {
+	// Node is the name of the node that failed.
+	Node string
+	// Path is the chain of names from the requested root down to Node,
+	// such as response -> inbox -> notifications -> user.
+	Path []string
+	// Kind describes why the node failed.
+	Kind ErrorKind
+	// Err is the underlying error.
+	Err error
+	// Attempts is the number of times the Factory was called, when Kind is
+	// FactoryFailed or FactoryTimedOut.
+	Attempts int
+}
+
+func newResolutionError(path []string, kind ErrorKind, err error) *ResolutionError {
+	return &ResolutionError{
+		Node: path[len(path)-1],
+		Path: path,
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+func (e *ResolutionError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s after %d attempts at %s: %v", e.Kind, e.Attempts, strings.Join(e.Path, " -> "), e.Err)
+	}
+	return fmt.Sprintf("%s at %s: %v", e.Kind, strings.Join(e.Path, " -> "), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ResolutionError) Unwrap() error {
+	return e.Err
+}
+
+// contextError describes why the Context of a resolution is done.
+// If the resolution was aborted because a node failed, that node's error is
+// returned. Otherwise, the Context error is attributed to the last name in path,
+// or returned as is when path is empty.
+func contextError(ctx context.Context, path []string) error {
+	cause := context.Cause(ctx)
+	if resolutionErr, ok := cause.(*ResolutionError); ok {
+		return resolutionErr
+	}
+	if len(path) == 0 {
+		return cause
+	}
+	return newResolutionError(path, ContextDone, cause)
+}
+
+// PanicError is returned when a Factory or Condition panics.
+type PanicError struct {
+	// Node is the name of the node whose Factory or Condition panicked.
+	Node string
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func newPanicError(name string, value interface{}) *PanicError {
+	return &PanicError{
+		Node:  name,
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Node, e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// TimeoutError is returned when a Factory exceeds the timeout it was registered with.
+type TimeoutError struct {
+	// Node is the name of the node that timed out.
+	Node string
+	// Timeout is the timeout the node was registered with.
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("node %s exceeded its %s budget", e.Node, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
@@ -43,22 +43,21 @@ func main() {
 	username := getEnv(envUsername, defaultUsername)
 	password := getEnvSensitive(envPassword, defaultPassword)
 
+	var deps struct {
+		Client     rpcdpb.UserServiceClient `quarry:"userdClient"`
+		ClientConn *grpc.ClientConn         `quarry:"userdClientConn"`
+	}
+	if err := quarry.Inject(context.Background(), q, nil, &deps); err != nil {
+		log.Fatalf("error creating client: %v", err)
+	}
+
 	defer func() {
-		userdClientConn, err := q.Get(context.Background(), nil, "userdClientConn")
-		if err != nil {
-			log.Fatal(err)
-		}
-		if err := userdClientConn.(*grpc.ClientConn).Close(); err != nil {
+		if err := deps.ClientConn.Close(); err != nil {
 			log.Fatal(err)
 		}
 	}()
 
-	userdClientInterface, err := q.Get(context.Background(), nil, "userdClient")
-	if err != nil {
-		log.Fatalf("error creating client: %v", err)
-	}
-
-	client := userdClientInterface.(rpcdpb.UserServiceClient)
+	client := deps.Client
 
 	// CreateUser.
 	createUserRequest := &rpcdpb.CreateUserRequest{
@@ -30,15 +30,15 @@ func init() {
 
 	q.MustAddFactory("bind", quarry.Provider(getEnv(envBind, defaultBind)))
 
-	q.MustAddFactory("userdListener", quarry.Singleton(buildUserdListener))
+	q.MustAddFactory("userdListener", buildUserdListener, quarry.AsSingleton())
 	q.MustAddDependency("userdListener", "bind")
 
 	q.MustAddFactory("grpcServerOptions", quarry.Provider([]grpc.ServerOption(nil)))
 
-	q.MustAddFactory("grpcServer", quarry.Singleton(buildGrpcServer))
+	q.MustAddFactory("grpcServer", buildGrpcServer, quarry.AsSingleton())
 	q.MustAddDependency("grpcServer", "grpcServerOptions")
 
-	q.MustAddFactory("userdRunner", quarry.Singleton(buildUserdRunner))
+	q.MustAddFactory("userdRunner", buildUserdRunner, quarry.AsSingleton())
 	q.MustAddDependency("userdRunner", "registerUserService")
 	q.MustAddDependency("userdRunner", "userdListener")
 	q.MustAddDependency("userdRunner", "grpcServer")
@@ -56,7 +56,7 @@ func main() {
 	}
 }
 
-func buildUserdListener(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildUserdListener(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	bind := deps["bind"].(string)
 
 	log.Printf("listening on %s", bind)
@@ -64,7 +64,7 @@ func buildUserdListener(ctx context.Context, deps quarry.Dependencies) (interfac
 	return net.Listen("tcp", bind)
 }
 
-func buildUserdRunner(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildUserdRunner(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	userdListener := deps["userdListener"].(net.Listener)
 	grpcServer := deps["grpcServer"].(*grpc.Server)
 
@@ -75,7 +75,7 @@ func buildUserdRunner(ctx context.Context, deps quarry.Dependencies) (interface{
 	return userdRunner, nil
 }
 
-func buildGrpcServer(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildGrpcServer(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	grpcServerOptions := deps["grpcServerOptions"].([]grpc.ServerOption)
 
 	return grpc.NewServer(grpcServerOptions...), nil
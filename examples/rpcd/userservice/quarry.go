@@ -13,27 +13,27 @@ import (
 func init() {
 	q := rpcdquarry.Default()
 
-	q.MustAddFactory("userService", quarry.Singleton(buildUserService))
+	q.MustAddFactory("userService", buildUserService, quarry.AsSingleton())
 
-	q.MustAddFactory("registerUserService", quarry.Singleton(registerUserService))
+	q.MustAddFactory("registerUserService", registerUserService, quarry.AsSingleton())
 	q.MustAddDependency("registerUserService", "grpcServer")
 	q.MustAddDependency("registerUserService", "userService")
 
 	q.MustAddFactory("userdDialOptions", quarry.Provider([]grpc.DialOption{grpc.WithInsecure()}))
 
-	q.MustAddFactory("userdClientConn", quarry.Singleton(buildUserdClientConn))
+	q.MustAddFactory("userdClientConn", buildUserdClientConn, quarry.AsSingleton())
 	q.MustAddDependency("userdClientConn", "userdAddress")
 	q.MustAddDependency("userdClientConn", "userdDialOptions")
 
-	q.MustAddFactory("userdClient", quarry.Singleton(buildUserdClient))
+	q.MustAddFactory("userdClient", buildUserdClient, quarry.AsSingleton())
 	q.MustAddDependency("userdClient", "userdClientConn")
 }
 
-func buildUserService(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildUserService(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	return &userService{}, nil
 }
 
-func registerUserService(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func registerUserService(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	grpcServer := deps["grpcServer"].(*grpc.Server)
 	userService := deps["userService"].(*userService)
 
@@ -41,14 +41,14 @@ func registerUserService(ctx context.Context, deps quarry.Dependencies) (interfa
 	return nil, nil
 }
 
-func buildUserdClientConn(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildUserdClientConn(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	address := deps["userdAddress"].(string)
 	userdDialOptions := deps["userdDialOptions"].([]grpc.DialOption)
 
 	return grpc.DialContext(ctx, address, userdDialOptions...)
 }
 
-func buildUserdClient(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildUserdClient(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	userdClientConn := deps["userdClientConn"].(*grpc.ClientConn)
 
 	return rpcdpb.NewUserServiceClient(userdClientConn), nil
@@ -10,7 +10,7 @@ import (
 func init() {
 	q := rpcdquarry.Default()
 
-	q.MustAddFactory("userStorage", quarry.Singleton(buildUserStorage))
+	q.MustAddFactory("userStorage", buildUserStorage, quarry.AsSingleton())
 	q.MustAddDependency("userStorage", "secret")
 
 	q.MustAddFactory("user", fetchUser)
@@ -23,7 +23,7 @@ func init() {
 	q.MustAddDependency("loginUser", "userStorage")
 }
 
-func buildUserStorage(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+func buildUserStorage(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	secret := deps["secret"].(string)
 
 	return newUserStorage(ctx, secret)
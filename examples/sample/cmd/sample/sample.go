@@ -14,6 +14,7 @@ import (
 
 func main() {
 	graph := samplequarry.Default()
+	graph.MustFreeze()
 
 	request := &samplepb.SampleRequest{
 		Token:      "0xdeadbeef",
@@ -13,7 +13,7 @@ func init() {
 	graph := samplequarry.Default()
 
 	// Dependencies for NotificationService would normally be provided by the graph.
-	// For a service-like object, consider a quarry.Singleton.
+	// For a service-like object, consider registering it with quarry.AsSingleton.
 	notificationService := &NotificationService{}
 	graph.MustAddFactory("notificationService", quarry.Provider(notificationService))
 
@@ -28,7 +28,7 @@ func init() {
 	graph.MustAddDependency("inbox", "notifications")
 	// unreadOption indicates that this dependency is only filled when the unread option
 	// is passed in by parameters.
-	graph.MustAddDependency("inbox", "unreadNotifications", unreadOption)
+	graph.MustAddEdge("inbox", "unreadNotifications", quarry.WhenAll(quarry.NamedCondition("showUnread", unreadOption)))
 
 }
 
@@ -82,8 +82,11 @@ func fetchUnreadNotifications(ctx context.Context, params interface{}, deps quar
 
 func fetchInbox(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
 	notifications := deps["notifications"].([]*samplepb.Notification)
-	// unreadNotifications is conditional, so it could come in as a null value.
-	unreadNotifications, _ := deps["unreadNotifications"].([]*samplepb.Notification)
+	// unreadNotifications is conditional, so it is skipped unless the unread option is set.
+	var unreadNotifications []*samplepb.Notification
+	if !quarry.Skipped(ctx, "unreadNotifications") {
+		unreadNotifications = deps["unreadNotifications"].([]*samplepb.Notification)
+	}
 
 	inbox := &samplepb.Inbox{
 		Notifications:       notifications,
@@ -13,7 +13,7 @@ func init() {
 	graph := samplequarry.Default()
 
 	// Dependencies for UserService would normally be provided by the graph.
-	// For a service-like object, consider a quarry.Singleton.
+	// For a service-like object, consider registering it with quarry.AsSingleton.
 	userService := &UserService{}
 	graph.MustAddFactory("userService", quarry.Provider(userService))
 
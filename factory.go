@@ -5,13 +5,23 @@ import (
 	"sync"
 )
 
-// Dependencies is a map of named values that are provided to Factories.
-type Dependencies map[string]interface{}
+// Result is the outcome of a resolution.
+type Result struct {
+	// Value is the object that was requested.
+	Value interface{}
+	// Fallbacks holds the names of the nodes, in sorted order, whose
+	// fallback Factory supplied their value because their Factory failed.
+	Fallbacks []string
+}
 
-// Contains returns true when these dependencies has the given key.
-func (d Dependencies) Contains(key string) bool {
-	_, ok := d[key]
-	return ok
+// UsedFallback returns true if the named node's value came from its fallback Factory.
+func (r *Result) UsedFallback(name string) bool {
+	for _, fallback := range r.Fallbacks {
+		if fallback == name {
+			return true
+		}
+	}
+	return false
 }
 
 // Factory is a function that executes and creates a result.
@@ -26,15 +36,97 @@ func Provider(value interface{}) Factory {
 
 // Singleton wraps a Factory-like function to ensure that it is used only once.
 // Unlike Factory, the function does not use parameters.
-// Its return value will be re-used, including errors.
+// Its return value will be re-used, including errors and panics.
+// Children of a Quarry share the value, unless the child overrides the node
+// or anything it transitively depends on, in which case the child gets its own.
+// The value is also created anew when Override changes the node or anything
+// it transitively depends on.
+// To have the node reported as a Singleton, by Nodes and in graphs, register
+// its Factory with AsSingleton instead.
 func Singleton(factory func(ctx context.Context, deps Dependencies) (interface{}, error)) Factory {
-	var once sync.Once
-	var result interface{}
-	var err error
+	s := &singleton{}
 	return func(ctx context.Context, params interface{}, deps Dependencies) (interface{}, error) {
-		once.Do(func() {
-			result, err = factory(ctx, deps)
-		})
-		return result, err
+		return s.value(scopeFrom(ctx)).get(ctx, deps, factory)
+	}
+}
+
+// AsSingleton makes a Factory behave like one created by Singleton, calling it
+// with nil parameters, and marks the node as a Singleton.
+func AsSingleton() FactoryOption {
+	return func(n *node) {
+		n.factory = asSingleton(n.factory)
+		n.singleton = true
+	}
+}
+
+// asSingleton wraps a Factory with Singleton.
+func asSingleton(factory Factory) Factory {
+	return Singleton(func(ctx context.Context, deps Dependencies) (interface{}, error) {
+		return factory(ctx, nil, deps)
+	})
+}
+
+// singleton identifies a Singleton and holds the value shared by every quarry.
+// Values of a child are held by the child, so that they are released with it.
+type singleton struct {
+	m      sync.Mutex
+	shared *singletonValue
+}
+
+// value returns the value of the Singleton in a scope.
+func (s *singleton) value(sc scope) *singletonValue {
+	if sc.q != nil {
+		return sc.q.singletonValue(s, sc.epoch)
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.shared = s.shared.current(sc.epoch)
+	return s.shared
+}
+
+// singletonValue returns the value of a Singleton scoped to this quarry.
+func (q *quarryImpl) singletonValue(s *singleton, epoch uint64) *singletonValue {
+	q.singletonsMu.Lock()
+	defer q.singletonsMu.Unlock()
+	if q.singletons == nil {
+		q.singletons = make(map[*singleton]*singletonValue)
+	}
+	value := q.singletons[s].current(epoch)
+	q.singletons[s] = value
+	return value
+}
+
+// singletonValue is the value of a Singleton within a scope.
+type singletonValue struct {
+	// epoch is the epoch of the scope the value was created in.
+	epoch      uint64
+	once       sync.Once
+	result     interface{}
+	err        error
+	panicked   bool
+	panicValue interface{}
+}
+
+// current returns v, or a new value if there is none or v was created in an
+// earlier epoch.
+func (v *singletonValue) current(epoch uint64) *singletonValue {
+	if v == nil || v.epoch != epoch {
+		return &singletonValue{epoch: epoch}
+	}
+	return v
+}
+
+func (v *singletonValue) get(ctx context.Context, deps Dependencies, factory func(ctx context.Context, deps Dependencies) (interface{}, error)) (interface{}, error) {
+	v.once.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				v.panicked, v.panicValue = true, r
+			}
+		}()
+		v.result, v.err = factory(ctx, deps)
+	})
+	if v.panicked {
+		panic(v.panicValue)
 	}
+	return v.result, v.err
 }
@@ -55,3 +55,27 @@ func TestSingleton_returnsSameValue(t *testing.T) {
 
 	assert.Equal(t, val1, val2)
 }
+
+func TestSingleton_repeatsPanic(t *testing.T) {
+	onceFactory := quarry.Singleton(func(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+		panic("some panic")
+	})
+
+	assert.Panics(t, func() { onceFactory(nil, nil, nil) })
+	assert.Panics(t, func() { onceFactory(nil, nil, nil) })
+}
+
+func TestAsSingleton(t *testing.T) {
+	q := quarry.New()
+	count, counter := factoryCounter()
+	q.MustAddFactory("db", counter, quarry.AsSingleton())
+	q.MustAddFactory("cache", counter)
+
+	first := q.MustGet(context.Background(), "first", "db")
+	second := q.MustGet(context.Background(), "second", "db")
+	nodes := q.Nodes()
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), *count)
+	assert.Equal(t, []quarry.NodeInfo{{Name: "cache"}, {Name: "db", Singleton: true}}, nodes)
+}
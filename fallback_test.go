@@ -0,0 +1,97 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarryImpl_AddFallback_usedWhenFactoryFails(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryWithDeps(factoryOk(), "a"))
+	q.MustAddFactory("a", factoryError())
+	q.MustAddFallback("a", quarry.Provider("fallback"))
+	q.MustAddDependency("root", "a")
+
+	result, err := q.Resolve(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, result.Fallbacks)
+	assert.True(t, result.UsedFallback("a"))
+	assert.False(t, result.UsedFallback("root"))
+}
+
+func TestQuarryImpl_AddFallback_receivesDependencies(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryError())
+	q.MustAddFactory("a", quarry.Provider("a-value"))
+	q.MustAddFallback("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return deps["a"], nil
+	})
+	q.MustAddDependency("root", "a")
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "a-value", value)
+}
+
+func TestQuarryImpl_AddFallback_usedWhenFactoryTimesOut(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factorySlow(time.Second), quarry.WithTimeout(10*time.Millisecond))
+	q.MustAddFallback("root", quarry.Provider("fallback"))
+
+	result, err := q.Resolve(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", result.Value)
+	assert.True(t, result.UsedFallback("root"))
+}
+
+func TestQuarryImpl_AddFallback_fallbackErrorResultsInError(t *testing.T) {
+	someErr := errors.New("some-error")
+	fallbackErr := errors.New("fallback-error")
+	q := quarry.New()
+	q.MustAddFactory("root", factoryErr(someErr))
+	q.MustAddFallback("root", factoryErr(fallbackErr))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.True(t, errors.Is(err, someErr))
+	assert.True(t, errors.Is(err, fallbackErr))
+}
+
+func TestQuarryImpl_AddFallback_notUsedOnSuccess(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", quarry.Provider("primary"))
+	q.MustAddFallback("root", quarry.Provider("fallback"))
+
+	result, err := q.Resolve(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "primary", result.Value)
+	assert.Empty(t, result.Fallbacks)
+}
+
+func TestQuarryImpl_AddFallback_missingFactoryResultsInError(t *testing.T) {
+	q := quarry.New()
+
+	err := q.AddFallback("root", factoryOk())
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_AddFallback_duplicateResultsInError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+
+	err1 := q.AddFallback("root", factoryOk())
+	err2 := q.AddFallback("root", factoryOk())
+
+	assert.NoError(t, err1)
+	assert.Error(t, err2)
+}
@@ -0,0 +1,310 @@
+package quarry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GraphOption configures how WriteDOT and WriteMermaid render a graph.
+type GraphOption func(o *graphOptions)
+
+type graphOptions struct {
+	// highlight is the name of the node whose subgraph is highlighted, if any.
+	highlight string
+}
+
+// Highlight emphasizes root and every node it may need to resolve, such as
+// everything that goes into building a response, and dims all other nodes.
+func Highlight(root string) GraphOption {
+	return func(o *graphOptions) {
+		o.highlight = root
+	}
+}
+
+// WriteDOT writes the graph of a Quarry in the Graphviz DOT language.
+// Conditional dependencies are dashed and labeled with their conditions,
+// optional dependencies are dotted, Singletons have a double border, switches
+// are diamonds, aliases are ellipses and missing factories are red.
+func WriteDOT(w io.Writer, q Quarry, options ...GraphOption) error {
+	g := newGraph(q, options)
+	var b strings.Builder
+	b.WriteString("digraph quarry {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	for _, n := range g.nodes {
+		var attrs []string
+		label := n.name
+		switch {
+		case n.missing():
+			label += "\n(missing)"
+			attrs = append(attrs, "style=dashed", "color=red", "fontcolor=red")
+		case n.is(NodeSwitch):
+			attrs = append(attrs, "shape=diamond")
+		case n.is(NodeAlias):
+			attrs = append(attrs, "shape=ellipse")
+		case n.singleton():
+			label += "\n(singleton)"
+			attrs = append(attrs, "peripheries=2")
+		}
+		attrs = append(attrs, g.dotHighlight(n.name, !n.missing())...)
+		if label != n.name {
+			attrs = append(attrs, "label="+dotQuote(label))
+		}
+		fmt.Fprintf(&b, "\t%s%s;\n", dotQuote(n.name), dotAttrs(attrs))
+	}
+	for _, n := range g.nodes {
+		for _, e := range n.edges {
+			var attrs []string
+			switch {
+			case e.conditional:
+				attrs = append(attrs, "style=dashed")
+			case e.optional:
+				attrs = append(attrs, "style=dotted")
+			}
+			if e.label != "" {
+				attrs = append(attrs, "label="+dotQuote(e.label))
+			}
+			attrs = append(attrs, g.dotHighlight(n.name, true)...)
+			fmt.Fprintf(&b, "\t%s -> %s%s;\n", dotQuote(n.name), dotQuote(e.dependsOn), dotAttrs(attrs))
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph of a Quarry as a Mermaid flowchart.
+// Conditional and optional dependencies are dotted and labeled, Singletons
+// are subroutines, switches are rhombuses, aliases are stadiums and missing
+// factories are red.
+func WriteMermaid(w io.Writer, q Quarry, options ...GraphOption) error {
+	g := newGraph(q, options)
+	// Names may contain characters Mermaid does not allow in ids, such as
+	// the dots of Module prefixes, so nodes are identified by position.
+	ids := make(map[string]string, len(g.nodes))
+	for i, n := range g.nodes {
+		ids[n.name] = fmt.Sprintf("n%d", i)
+	}
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	var missing, highlighted []string
+	for _, n := range g.nodes {
+		id, label := ids[n.name], mermaidQuote(n.name)
+		switch {
+		case n.missing():
+			fmt.Fprintf(&b, "\t%s[%s]\n", id, mermaidQuote(n.name+" (missing)"))
+			missing = append(missing, id)
+		case n.is(NodeSwitch):
+			fmt.Fprintf(&b, "\t%s{%s}\n", id, label)
+		case n.is(NodeAlias):
+			fmt.Fprintf(&b, "\t%s([%s])\n", id, label)
+		case n.singleton():
+			fmt.Fprintf(&b, "\t%s[[%s]]\n", id, label)
+		default:
+			fmt.Fprintf(&b, "\t%s[%s]\n", id, label)
+		}
+		if g.highlighted.Contains(n.name) {
+			highlighted = append(highlighted, id)
+		}
+	}
+	for _, n := range g.nodes {
+		for _, e := range n.edges {
+			arrow := "-->"
+			if e.conditional || e.optional {
+				arrow = "-.->"
+			}
+			if e.label != "" {
+				arrow += "|" + mermaidQuote(e.label) + "|"
+			}
+			fmt.Fprintf(&b, "\t%s %s %s\n", ids[n.name], arrow, ids[e.dependsOn])
+		}
+	}
+	if len(missing) > 0 {
+		b.WriteString("\tclassDef missing stroke:red,color:red,stroke-dasharray:5 5\n")
+		fmt.Fprintf(&b, "\tclass %s missing\n", strings.Join(missing, ","))
+	}
+	if g.highlighted != nil {
+		b.WriteString("\tclassDef highlight stroke-width:3px\n")
+		if len(highlighted) > 0 {
+			fmt.Fprintf(&b, "\tclass %s highlight\n", strings.Join(highlighted, ","))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// graph is a snapshot of the nodes of a Quarry, for rendering.
+type graph struct {
+	// nodes are sorted by name.
+	nodes []*graphNode
+	// highlighted holds the names of the highlighted nodes, or is nil if
+	// nothing is highlighted.
+	highlighted stringSet
+}
+
+// graphNode is a snapshot of a node and its dependencies.
+type graphNode struct {
+	name string
+	// info is nil if no Factory is registered under name.
+	info  *NodeInfo
+	edges []graphEdge
+	// successors are the names of every node it may need to resolve.
+	successors []string
+}
+
+func (n *graphNode) missing() bool {
+	return n.info == nil
+}
+
+func (n *graphNode) is(kind NodeKind) bool {
+	return n.info != nil && n.info.Kind == kind
+}
+
+func (n *graphNode) singleton() bool {
+	return n.info != nil && n.info.Singleton
+}
+
+// graphEdge is a snapshot of a dependency, or of a target of a switch or alias.
+type graphEdge struct {
+	dependsOn   string
+	label       string
+	conditional bool
+	optional    bool
+}
+
+// newGraph takes a snapshot of every node registered in q, depended upon or
+// declaring dependencies, including missing ones.
+func newGraph(q Quarry, options []GraphOption) *graph {
+	var o graphOptions
+	for _, option := range options {
+		option(&o)
+	}
+	g := &graph{}
+	byName := make(map[string]*graphNode)
+	add := func(name string, info *NodeInfo) {
+		n := &graphNode{name: name, info: info}
+		g.nodes = append(g.nodes, n)
+		byName[name] = n
+	}
+	for _, info := range q.Nodes() {
+		info := info
+		add(info.Name, &info)
+	}
+	if lister, ok := q.(parentLister); ok {
+		for _, name := range lister.unregisteredParents() {
+			add(name, nil)
+		}
+	}
+	// Missing nodes that are depended upon are added as they are found.
+	for i := 0; i < len(g.nodes); i++ {
+		n := g.nodes[i]
+		for _, e := range q.DependenciesOf(n.name) {
+			n.edges = append(n.edges, newGraphEdge(n, e))
+			n.successors = append(n.successors, e.DependsOn)
+			n.successors = append(n.successors, e.Prerequisites...)
+		}
+		for _, dependsOn := range n.successors {
+			if _, ok := byName[dependsOn]; !ok {
+				add(dependsOn, nil)
+			}
+		}
+	}
+	sort.Slice(g.nodes, func(i, j int) bool {
+		return g.nodes[i].name < g.nodes[j].name
+	})
+	if o.highlight != "" {
+		g.highlight(byName, o.highlight)
+	}
+	return g
+}
+
+// parentLister lists the nodes that declare dependencies without being
+// registered, which are otherwise only found by asking every node for its
+// dependents.
+type parentLister interface {
+	unregisteredParents() []string
+}
+
+func (q *quarryImpl) unregisteredParents() []string {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	var parents []string
+	for _, name := range sortedNames(q.names()) {
+		if n, edges, ok := q.lookupLocked(name); !ok && len(successorsOf(n, edges)) > 0 {
+			parents = append(parents, name)
+		}
+	}
+	return parents
+}
+
+// newGraphEdge labels a dependency with its conditions and whether it is
+// optional, such as "showUnread && condition on user (optional)".
+func newGraphEdge(parent *graphNode, e EdgeInfo) graphEdge {
+	label := strings.Join(e.Conditions, " && ")
+	if parent.is(NodeAlias) {
+		label = "alias"
+	}
+	if e.Optional {
+		label = strings.TrimSpace(label + " (optional)")
+	}
+	return graphEdge{
+		dependsOn:   e.DependsOn,
+		label:       label,
+		conditional: e.Conditional(),
+		optional:    e.Optional,
+	}
+}
+
+// highlight marks root and every node it may need to resolve.
+func (g *graph) highlight(byName map[string]*graphNode, root string) {
+	g.highlighted = newStringSet()
+	stack := []string{root}
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		n, ok := byName[name]
+		if !ok || g.highlighted.Contains(name) {
+			continue
+		}
+		g.highlighted.Add(name)
+		stack = append(stack, n.successors...)
+	}
+}
+
+// dotHighlight returns the attributes of a highlighted node or edge, or of a
+// dimmed one if dim is set.
+func (g *graph) dotHighlight(name string, dim bool) []string {
+	switch {
+	case g.highlighted == nil:
+		return nil
+	case g.highlighted.Contains(name):
+		return []string{"penwidth=2"}
+	case dim:
+		return []string{"color=gray", "fontcolor=gray"}
+	}
+	return nil
+}
+
+// dotQuote quotes an identifier or label for DOT.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// dotAttrs formats a DOT attribute list, or nothing if there are no attributes.
+func dotAttrs(attrs []string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(attrs, ", ") + "]"
+}
+
+// mermaidQuote quotes a label for Mermaid.
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,177 @@
+package quarry_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func drawnGraph() quarry.Quarry {
+	q := quarry.New()
+	q.MustAddFactory("response", factoryOk())
+	q.MustAddFactory("user", factoryOk())
+	q.MustAddFactory("db", factoryValue("db"), quarry.AsSingleton())
+	q.MustAddFactory("unread", factoryOk())
+	q.MustAddFactory("unused", factoryOk())
+	q.MustAddSwitch("storage", quarry.Case(quarry.Named("testing", yes), "memory"), quarry.Default("db"))
+	q.MustAddFactory("memory", factoryOk())
+	q.MustAddAlias("database", "db")
+	q.MustAddDependency("response", "user")
+	q.MustAddEdge("response", "unread", quarry.WhenAll(quarry.Named("showUnread", yes)))
+	q.MustAddEdge("response", "stats", quarry.Optional())
+	q.MustAddDependency("user", "storage")
+	q.MustAddDependency("unused", "database")
+	return q
+}
+
+func TestWriteDOT(t *testing.T) {
+	q := drawnGraph()
+	var b bytes.Buffer
+
+	err := quarry.WriteDOT(&b, q)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `digraph quarry {
+	node [shape=box];
+	"database" [shape=ellipse];
+	"db" [peripheries=2, label="db\n(singleton)"];
+	"memory";
+	"response";
+	"stats" [style=dashed, color=red, fontcolor=red, label="stats\n(missing)"];
+	"storage" [shape=diamond];
+	"unread";
+	"unused";
+	"user";
+	"database" -> "db" [label="alias"];
+	"response" -> "stats" [style=dotted, label="(optional)"];
+	"response" -> "unread" [style=dashed, label="showUnread"];
+	"response" -> "user";
+	"storage" -> "memory" [style=dashed, label="testing"];
+	"storage" -> "db" [style=dashed, label="default"];
+	"unused" -> "database";
+	"user" -> "storage";
+}
+`, b.String())
+}
+
+func TestWriteDOT_highlight(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("response", factoryOk())
+	q.MustAddFactory("user", factoryOk())
+	q.MustAddFactory("unused", factoryOk())
+	q.MustAddDependency("response", "user")
+	var b bytes.Buffer
+
+	err := quarry.WriteDOT(&b, q, quarry.Highlight("response"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `digraph quarry {
+	node [shape=box];
+	"response" [penwidth=2];
+	"unused" [color=gray, fontcolor=gray];
+	"user" [penwidth=2];
+	"response" -> "user" [penwidth=2];
+}
+`, b.String())
+}
+
+func TestWriteMermaid(t *testing.T) {
+	q := drawnGraph()
+	var b bytes.Buffer
+
+	err := quarry.WriteMermaid(&b, q, quarry.Highlight("user"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, `flowchart TD
+	n0(["database"])
+	n1[["db"]]
+	n2["memory"]
+	n3["response"]
+	n4["stats (missing)"]
+	n5{"storage"}
+	n6["unread"]
+	n7["unused"]
+	n8["user"]
+	n0 -->|"alias"| n1
+	n3 -.->|"(optional)"| n4
+	n3 -.->|"showUnread"| n6
+	n3 --> n8
+	n5 -.->|"testing"| n2
+	n5 -.->|"default"| n1
+	n7 --> n0
+	n8 --> n5
+	classDef missing stroke:red,color:red,stroke-dasharray:5 5
+	class n4 missing
+	classDef highlight stroke-width:3px
+	class n1,n2,n5,n8 highlight
+`, b.String())
+}
+
+func TestWriteDOT_describesConditions(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddFactory("b", factoryOk())
+	q.MustAddFactory("user", factoryOk())
+	q.MustAddEdge("a", "b",
+		quarry.WhenAll(quarry.Or(quarry.Named("x", yes), quarry.Not(quarry.Named("y", no)))),
+		quarry.WhenResolved(isAdmin, "user"),
+		quarry.Optional())
+	var b bytes.Buffer
+
+	err := quarry.WriteDOT(&b, q)
+
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), `"a" -> "b" [style=dashed, label="(x || !y) && condition on user (optional)"];`)
+}
+
+func TestWriteDOT_unregisteredParent(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddDependency("ghost", "a")
+	q.MustAddDependency("phantom", "ghost")
+	var b bytes.Buffer
+
+	err := quarry.WriteDOT(&b, q)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `digraph quarry {
+	node [shape=box];
+	"a";
+	"ghost" [style=dashed, color=red, fontcolor=red, label="ghost\n(missing)"];
+	"phantom" [style=dashed, color=red, fontcolor=red, label="phantom\n(missing)"];
+	"ghost" -> "a";
+	"phantom" -> "ghost";
+}
+`, b.String())
+}
+
+func TestWriteDOT_child(t *testing.T) {
+	parent := quarry.New()
+	parent.MustAddFactory("a", factoryOk())
+	parent.MustAddFactory("b", factoryOk())
+	parent.MustAddDependency("a", "b")
+	child := parent.Child()
+	child.MustAddFactory("c", factoryOk())
+	child.MustAddDependency("a", "c")
+	var b bytes.Buffer
+
+	err := quarry.WriteDOT(&b, child)
+
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), `"a" -> "c";`)
+	assert.NotContains(t, b.String(), `"a" -> "b";`)
+}
+
+func TestWriteDOT_wrappedQuarry(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	wrapped := struct{ quarry.Quarry }{q}
+	var b bytes.Buffer
+
+	err := quarry.WriteDOT(&b, wrapped)
+
+	assert.NoError(t, err)
+	assert.Contains(t, b.String(), `"a";`)
+}
@@ -0,0 +1,51 @@
+package quarry
+
+import (
+	"context"
+	"time"
+)
+
+// FactoryCall describes a call of a Factory during a resolution.
+type FactoryCall struct {
+	// Name is the name of the node whose Factory was called.
+	Name string
+	// Params are the parameters of the resolution.
+	Params interface{}
+	// Start is when the Factory was called.
+	Start time.Time
+	// Duration is how long the Factory took, including retries and its fallback.
+	Duration time.Duration
+	// Value and Err are what the Factory returned.
+	Value interface{}
+	Err   error
+	// Attempts is the number of times the Factory was called, see ResolutionError.
+	Attempts int
+	// Fallback is true if the value came from the node's fallback Factory.
+	Fallback bool
+}
+
+// Hook observes the factories called during resolutions.
+// It may be called from several goroutines at once.
+type Hook func(call FactoryCall)
+
+// hookKey is the Context key of the Hook of a resolution.
+type hookKey struct{}
+
+// WithHook returns a Context that reports every Factory called by
+// resolutions using it to hook, after any hooks ctx already reports to.
+func WithHook(ctx context.Context, hook Hook) context.Context {
+	if previous := hookFrom(ctx); previous != nil {
+		next := hook
+		hook = func(call FactoryCall) {
+			previous(call)
+			next(call)
+		}
+	}
+	return context.WithValue(ctx, hookKey{}, hook)
+}
+
+// hookFrom returns the Hook of a resolution, or nil if there is none.
+func hookFrom(ctx context.Context) Hook {
+	hook, _ := ctx.Value(hookKey{}).(Hook)
+	return hook
+}
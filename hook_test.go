@@ -0,0 +1,70 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithHook(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddFactory("ok", factoryValue("value"))
+	q.MustAddFactory("failing", factoryError())
+	q.MustAddFallback("failing", factoryValue("fallback"))
+	q.MustAddDependency("root", "ok")
+	q.MustAddDependency("root", "failing")
+	var m sync.Mutex
+	calls := make(map[string]quarry.FactoryCall)
+	var order []string
+	ctx := quarry.WithHook(context.Background(), func(call quarry.FactoryCall) {
+		m.Lock()
+		calls[call.Name] = call
+		order = append(order, call.Name)
+		m.Unlock()
+	})
+
+	_, err := q.Get(ctx, "params", "root")
+
+	assert.NoError(t, err)
+	assert.Len(t, order, 3)
+	assert.Equal(t, "root", order[2])
+	assert.Equal(t, "value", calls["ok"].Value)
+	assert.Equal(t, "params", calls["ok"].Params)
+	assert.Equal(t, 1, calls["ok"].Attempts)
+	assert.True(t, calls["failing"].Fallback)
+	assert.Equal(t, "fallback", calls["failing"].Value)
+	assert.False(t, calls["root"].Start.IsZero())
+}
+
+func TestWithHook_reportsErrors(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("failing", factoryError())
+	var reported error
+	ctx := quarry.WithHook(context.Background(), func(call quarry.FactoryCall) {
+		reported = call.Err
+	})
+
+	_, err := q.Get(ctx, nil, "failing")
+
+	assert.True(t, errors.Is(err, reported))
+	assert.EqualError(t, reported, "some-error")
+}
+
+func TestWithHook_chainsHooks(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	var first, second int
+	ctx := quarry.WithHook(context.Background(), func(quarry.FactoryCall) { first++ })
+	ctx = quarry.WithHook(ctx, func(quarry.FactoryCall) { second++ })
+
+	_, err := q.Get(ctx, nil, "a")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first)
+	assert.Equal(t, 1, second)
+}
@@ -0,0 +1,125 @@
+package quarry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// injectTag is the struct tag used by Inject.
+const injectTag = "quarry"
+
+// injectField is a struct field to be filled by Inject.
+type injectField struct {
+	index int
+	name  string
+	// optional fields are left untouched when no Factory is registered.
+	optional bool
+	// omitEmpty fields are left untouched when the resolved value is nil.
+	omitEmpty bool
+}
+
+// Inject fills the exported fields of the struct pointed to by target that are
+// tagged with the name of a node, such as `quarry:"userdClient"`.
+// All tagged nodes are fetched in a single resolution using GetAll.
+//
+// The tag name may be followed by comma-separated modifiers:
+//   - optional: the field is left untouched if no Factory is registered for the node.
+//   - omitempty: the field is left untouched if the node produces nil.
+//
+// Fields tagged with "-" are ignored.
+func Inject(ctx context.Context, q Quarry, params interface{}, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("inject target must be a non-nil pointer to a struct, got %T", target)
+	}
+	value = value.Elem()
+
+	fields, err := parseInjectFields(value.Type())
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, field := range fields {
+		if field.optional && !q.Has(field.name) {
+			continue
+		}
+		names = append(names, field.name)
+	}
+	results, err := q.GetAll(ctx, params, names...)
+	if err != nil {
+		return err
+	}
+
+	// Check every result before setting any field, so that a mismatched
+	// type leaves the target untouched.
+	for _, field := range fields {
+		result := results[field.name]
+		if result == nil {
+			continue
+		}
+		fieldType := value.Type().Field(field.index).Type
+		if !reflect.TypeOf(result).AssignableTo(fieldType) {
+			return fmt.Errorf("cannot inject %s of type %T into field %s of type %s",
+				field.name, result, value.Type().Field(field.index).Name, fieldType)
+		}
+	}
+	for _, field := range fields {
+		result, ok := results[field.name]
+		if !ok {
+			continue
+		}
+		fieldValue := value.Field(field.index)
+		if result == nil {
+			if !field.omitEmpty {
+				fieldValue.Set(reflect.Zero(fieldValue.Type()))
+			}
+			continue
+		}
+		fieldValue.Set(reflect.ValueOf(result))
+	}
+	return nil
+}
+
+// MustInject panics if Inject fails.
+func MustInject(ctx context.Context, q Quarry, params interface{}, target interface{}) {
+	if err := Inject(ctx, q, params, target); err != nil {
+		panic(err)
+	}
+}
+
+// parseInjectFields finds the tagged fields of a struct type.
+func parseInjectFields(structType reflect.Type) ([]injectField, error) {
+	var fields []injectField
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		tag, ok := structField.Tag.Lookup(injectTag)
+		if !ok || tag == "-" {
+			continue
+		}
+		if structField.PkgPath != "" {
+			return nil, fmt.Errorf("cannot inject unexported field %s", structField.Name)
+		}
+		parts := strings.Split(tag, ",")
+		field := injectField{
+			index: i,
+			name:  parts[0],
+		}
+		if field.name == "" {
+			return nil, fmt.Errorf("field %s has an empty %s tag", structField.Name, injectTag)
+		}
+		for _, modifier := range parts[1:] {
+			switch modifier {
+			case "optional":
+				field.optional = true
+			case "omitempty":
+				field.omitEmpty = true
+			default:
+				return nil, fmt.Errorf("field %s has unknown %s tag modifier %q", structField.Name, injectTag, modifier)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,152 @@
+package quarry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInject_fillsTaggedFields(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("name", quarry.Provider("world"))
+	q.MustAddFactory("count", quarry.Provider(3))
+	var target struct {
+		Name     string `quarry:"name"`
+		Count    int    `quarry:"count"`
+		Untagged string
+	}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "world", target.Name)
+	assert.Equal(t, 3, target.Count)
+	assert.Empty(t, target.Untagged)
+}
+
+func TestInject_sharesDependencies(t *testing.T) {
+	q := quarry.New()
+	count, counter := factoryCounter()
+	q.MustAddFactory("shared", counter)
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddFactory("b", factoryOk())
+	q.MustAddDependency("a", "shared")
+	q.MustAddDependency("b", "shared")
+	var target struct {
+		A interface{} `quarry:"a"`
+		B interface{} `quarry:"b"`
+	}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *count)
+}
+
+func TestInject_optionalMissingIsIgnored(t *testing.T) {
+	q := quarry.New()
+	target := struct {
+		Name string `quarry:"name,optional"`
+	}{Name: "default"}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "default", target.Name)
+}
+
+func TestInject_missingResultsInError(t *testing.T) {
+	q := quarry.New()
+	var target struct {
+		Name string `quarry:"name"`
+	}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.Error(t, err)
+}
+
+func TestInject_omitEmptyKeepsValue(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", quarry.Provider(nil))
+	q.MustAddFactory("b", quarry.Provider(nil))
+	value := 1
+	target := struct {
+		A *int `quarry:"a,omitempty"`
+		B *int `quarry:"b"`
+	}{A: &value, B: &value}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &value, target.A)
+	assert.Nil(t, target.B)
+}
+
+func TestInject_typeMismatchResultsInError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("name", quarry.Provider(1))
+	var target struct {
+		Name string `quarry:"name"`
+	}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.Error(t, err)
+}
+
+func TestInject_typeMismatchLeavesTargetUntouched(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("greeting", quarry.Provider("hello"))
+	q.MustAddFactory("name", quarry.Provider(1))
+	target := struct {
+		Greeting string `quarry:"greeting"`
+		Name     string `quarry:"name"`
+	}{Greeting: "unset"}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.EqualError(t, err, "cannot inject name of type int into field Name of type string")
+	assert.Equal(t, "unset", target.Greeting)
+}
+
+func TestInject_nonPointerResultsInError(t *testing.T) {
+	q := quarry.New()
+	var target struct{}
+
+	err := quarry.Inject(context.Background(), q, nil, target)
+
+	assert.Error(t, err)
+}
+
+func TestInject_unknownModifierResultsInError(t *testing.T) {
+	q := quarry.New()
+	var target struct {
+		Name string `quarry:"name,sometimes"`
+	}
+
+	err := quarry.Inject(context.Background(), q, nil, &target)
+
+	assert.Error(t, err)
+}
+
+func TestQuarryImpl_GetAll(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", quarry.Provider("a-value"))
+	q.MustAddFactory("b", quarry.Provider("b-value"))
+
+	results, err := q.GetAll(context.Background(), nil, "a", "b")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"a": "a-value", "b": "b-value"}, results)
+}
+
+func TestQuarryImpl_Has(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+
+	assert.True(t, q.Has("a"))
+	assert.False(t, q.Has("b"))
+}
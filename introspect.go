@@ -0,0 +1,246 @@
+package quarry
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// NodeKind is how a node was registered.
+type NodeKind int
+
+const (
+	// NodeFactory is a node registered with a Factory.
+	NodeFactory NodeKind = iota
+	// NodeSwitch is a node registered with AddSwitch.
+	NodeSwitch
+	// NodeAlias is a node registered with AddAlias.
+	NodeAlias
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case NodeFactory:
+		return "factory"
+	case NodeSwitch:
+		return "switch"
+	case NodeAlias:
+		return "alias"
+	default:
+		return fmt.Sprintf("NodeKind(%d)", int(k))
+	}
+}
+
+// NodeInfo describes a node registered in a Quarry.
+type NodeInfo struct {
+	Name string
+	Kind NodeKind
+	// Singleton is true if the node was registered with AsSingleton.
+	Singleton bool
+	// Module is the prefix of the Module the node was installed from, if any.
+	Module string
+	// Exported is true if the node may be depended upon from outside of its Module.
+	Exported bool
+	// Type is the type of value the node's Factory produces, when known.
+	Type reflect.Type
+}
+
+// EdgeInfo describes how a node depends on another. The targets of a switch
+// or alias are described as its dependencies.
+type EdgeInfo struct {
+	Parent    string
+	DependsOn string
+	// Key is the key the dependency is delivered to the parent Factory under.
+	Key string
+	// Conditions describe the conditions that must be met for the dependency
+	// to be fulfilled, see Predicate.String. For the targets of a switch, it
+	// holds the Predicate of the case, or "default" for the default case.
+	Conditions []string
+	// Prerequisites are the names of the nodes the conditions of the
+	// dependency are checked against, see WhenResolved.
+	Prerequisites []string
+	// Optional is true if the dependency tolerates a missing or failing Factory.
+	Optional bool
+}
+
+// Conditional returns true if the dependency may be skipped.
+func (e EdgeInfo) Conditional() bool {
+	return len(e.Conditions) > 0
+}
+
+func (q *quarryImpl) Nodes() []NodeInfo {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	types := q.allTypes()
+	var nodes []NodeInfo
+	for _, name := range q.registered() {
+		n, _, _ := q.lookupLocked(name)
+		info := NodeInfo{
+			Name:      name,
+			Singleton: n.singleton,
+			Module:    n.module,
+			Exported:  n.exported,
+			Type:      types[name],
+		}
+		switch {
+		case n.isSwitch():
+			info.Kind = NodeSwitch
+		case n.isAlias():
+			info.Kind = NodeAlias
+		}
+		nodes = append(nodes, info)
+	}
+	return nodes
+}
+
+func (q *quarryImpl) DependenciesOf(name string) []EdgeInfo {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	n, edges, _ := q.lookupLocked(name)
+	return edgesOf(name, n, edges)
+}
+
+func (q *quarryImpl) DependentsOf(name string) []EdgeInfo {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	var dependents []EdgeInfo
+	for _, parent := range sortedNames(q.names()) {
+		n, edges, _ := q.lookupLocked(parent)
+		for _, e := range edgesOf(parent, n, edges) {
+			if e.DependsOn == name {
+				dependents = append(dependents, e)
+			}
+		}
+	}
+	return dependents
+}
+
+func (q *quarryImpl) TopologicalOrder() []string {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	registered := q.registered()
+	order := make([]string, 0, len(registered))
+	visited := newStringSet()
+	var visit func(name string)
+	visit = func(name string) {
+		if visited.Contains(name) || !q.exists(name) {
+			return
+		}
+		visited.Add(name)
+		n, edges, _ := q.lookupLocked(name)
+		for _, dependsOn := range successorsOf(n, edges) {
+			visit(dependsOn)
+		}
+		order = append(order, name)
+	}
+	for _, name := range registered {
+		visit(name)
+	}
+	return order
+}
+
+func (q *quarryImpl) Roots() []string {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	registered := q.registered()
+	dependedUpon := newStringSet()
+	for _, name := range registered {
+		n, edges, _ := q.lookupLocked(name)
+		for _, dependsOn := range successorsOf(n, edges) {
+			dependedUpon.Add(dependsOn)
+		}
+	}
+	var roots []string
+	for _, name := range registered {
+		if !dependedUpon.Contains(name) {
+			roots = append(roots, name)
+		}
+	}
+	return roots
+}
+
+func (q *quarryImpl) Leaves() []string {
+	if !q.frozen.Load() {
+		q.mu.RLock()
+		defer q.mu.RUnlock()
+	}
+	var leaves []string
+	for _, name := range q.registered() {
+		n, edges, _ := q.lookupLocked(name)
+		if len(successorsOf(n, edges)) == 0 {
+			leaves = append(leaves, name)
+		}
+	}
+	return leaves
+}
+
+// registered returns the names of every node registered in this quarry or
+// its parents, in sorted order.
+// The caller must hold mu.
+func (q *quarryImpl) registered() []string {
+	var registered []string
+	for _, name := range sortedNames(q.names()) {
+		if q.exists(name) {
+			registered = append(registered, name)
+		}
+	}
+	return registered
+}
+
+// sortedNames returns the names in a set in sorted order.
+func sortedNames(names stringSet) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// edgesOf describes the dependencies of a node, or the targets of a switch
+// or alias, in a stable order.
+func edgesOf(parent string, n *node, edges edgeMap) []EdgeInfo {
+	switch {
+	case n != nil && n.isSwitch():
+		targets := make([]EdgeInfo, len(n.cases))
+		for i, c := range n.cases {
+			condition := "default"
+			if c.predicate != nil {
+				condition = c.predicate.String()
+			}
+			targets[i] = EdgeInfo{Parent: parent, DependsOn: c.target, Key: c.target, Conditions: []string{condition}}
+		}
+		return targets
+	case n != nil && n.isAlias():
+		return []EdgeInfo{{Parent: parent, DependsOn: n.alias, Key: n.alias}}
+	}
+	var dependencies []EdgeInfo
+	for _, dependsOn := range edges.sortedKeys() {
+		e := edges[dependsOn]
+		info := EdgeInfo{
+			Parent:    parent,
+			DependsOn: dependsOn,
+			Key:       e.key(dependsOn),
+			Optional:  e.optional,
+		}
+		for _, condition := range e.conditions {
+			info.Conditions = append(info.Conditions, condition.String())
+		}
+		for _, g := range e.guards {
+			info.Conditions = append(info.Conditions, g.String())
+			info.Prerequisites = append(info.Prerequisites, g.prerequisites...)
+		}
+		dependencies = append(dependencies, info)
+	}
+	return dependencies
+}
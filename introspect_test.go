@@ -0,0 +1,176 @@
+package quarry_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func introspectedGraph() quarry.Quarry {
+	q := quarry.New()
+	quarry.MustAddTypedFactory(q, quarry.NewKey[string]("response"), func(context.Context, interface{}, quarry.Dependencies) (string, error) {
+		return "", nil
+	})
+	q.MustAddFactory("user", factoryOk())
+	q.MustAddFactory("db", factoryValue("db"), quarry.AsSingleton())
+	q.MustAddFactory("memory", factoryOk())
+	q.MustAddFactory("unread", factoryOk())
+	q.MustAddSwitch("storage", quarry.Case(quarry.Named("testing", yes), "memory"), quarry.Default("db"))
+	q.MustAddAlias("database", "db")
+	q.MustAddDependency("response", "user")
+	q.MustAddEdge("response", "unread", quarry.WhenAll(quarry.Named("showUnread", yes)), quarry.WhenResolved(isAdmin, "user"), quarry.As("notifications"))
+	q.MustAddEdge("response", "stats", quarry.Optional())
+	q.MustAddDependency("user", "storage")
+	return q
+}
+
+func TestQuarryImpl_Nodes(t *testing.T) {
+	q := introspectedGraph()
+
+	nodes := q.Nodes()
+
+	assert.Equal(t, []quarry.NodeInfo{
+		{Name: "database", Kind: quarry.NodeAlias},
+		{Name: "db", Singleton: true},
+		{Name: "memory"},
+		{Name: "response", Type: reflect.TypeOf("")},
+		{Name: "storage", Kind: quarry.NodeSwitch},
+		{Name: "unread"},
+		{Name: "user"},
+	}, nodes)
+}
+
+func TestQuarryImpl_Nodes_module(t *testing.T) {
+	m := quarry.NewModule("users")
+	m.AddFactory("service", factoryOk())
+	m.AddFactory("cache", factoryOk())
+	m.Export("service")
+	q := quarry.New()
+	q.MustInstall(m)
+
+	nodes := q.Nodes()
+
+	assert.Equal(t, []quarry.NodeInfo{
+		{Name: "users.cache", Module: "users"},
+		{Name: "users.service", Module: "users", Exported: true},
+	}, nodes)
+}
+
+func TestQuarryImpl_DependenciesOf(t *testing.T) {
+	q := introspectedGraph()
+
+	dependencies := q.DependenciesOf("response")
+
+	assert.Equal(t, []quarry.EdgeInfo{
+		{Parent: "response", DependsOn: "stats", Key: "stats", Optional: true},
+		{
+			Parent:        "response",
+			DependsOn:     "unread",
+			Key:           "notifications",
+			Conditions:    []string{"showUnread", "condition on user"},
+			Prerequisites: []string{"user"},
+		},
+		{Parent: "response", DependsOn: "user", Key: "user"},
+	}, dependencies)
+	assert.False(t, dependencies[0].Conditional())
+	assert.True(t, dependencies[1].Conditional())
+}
+
+func TestQuarryImpl_DependenciesOf_switchAndAlias(t *testing.T) {
+	q := introspectedGraph()
+
+	storage := q.DependenciesOf("storage")
+	database := q.DependenciesOf("database")
+
+	assert.Equal(t, []quarry.EdgeInfo{
+		{Parent: "storage", DependsOn: "memory", Key: "memory", Conditions: []string{"testing"}},
+		{Parent: "storage", DependsOn: "db", Key: "db", Conditions: []string{"default"}},
+	}, storage)
+	assert.Equal(t, []quarry.EdgeInfo{
+		{Parent: "database", DependsOn: "db", Key: "db"},
+	}, database)
+}
+
+func TestQuarryImpl_DependentsOf(t *testing.T) {
+	q := introspectedGraph()
+
+	dependents := q.DependentsOf("db")
+
+	assert.Equal(t, []quarry.EdgeInfo{
+		{Parent: "database", DependsOn: "db", Key: "db"},
+		{Parent: "storage", DependsOn: "db", Key: "db", Conditions: []string{"default"}},
+	}, dependents)
+}
+
+func TestQuarryImpl_TopologicalOrder(t *testing.T) {
+	q := introspectedGraph()
+
+	order := q.TopologicalOrder()
+
+	assert.Equal(t, []string{"db", "database", "memory", "unread", "storage", "user", "response"}, order)
+}
+
+func TestQuarryImpl_TopologicalOrder_dependenciesComeFirst(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddFactory("b", factoryOk())
+	q.MustAddFactory("c", factoryOk())
+	q.MustAddFactory("d", factoryOk())
+	q.MustAddDependency("a", "d")
+	q.MustAddDependency("d", "c")
+	q.MustAddDependency("c", "b")
+
+	order := q.TopologicalOrder()
+
+	assert.Equal(t, []string{"b", "c", "d", "a"}, order)
+}
+
+func TestQuarryImpl_RootsAndLeaves(t *testing.T) {
+	q := introspectedGraph()
+
+	roots := q.Roots()
+	leaves := q.Leaves()
+
+	assert.Equal(t, []string{"database", "response"}, roots)
+	assert.Equal(t, []string{"db", "memory", "unread"}, leaves)
+}
+
+func TestQuarryImpl_Nodes_child(t *testing.T) {
+	parent := quarry.New()
+	parent.MustAddFactory("a", factoryOk())
+	parent.MustAddFactory("b", factoryOk())
+	parent.MustAddDependency("a", "b")
+	child := parent.Child()
+	child.MustAddFactory("c", factoryOk())
+	child.MustAddDependency("a", "c")
+
+	nodes := child.Nodes()
+	dependencies := child.DependenciesOf("a")
+	roots := child.Roots()
+
+	assert.Equal(t, []quarry.NodeInfo{{Name: "a"}, {Name: "b"}, {Name: "c"}}, nodes)
+	assert.Equal(t, []quarry.EdgeInfo{{Parent: "a", DependsOn: "c", Key: "c"}}, dependencies)
+	assert.Equal(t, []string{"a", "b"}, roots)
+	assert.Empty(t, parent.DependentsOf("c"))
+}
+
+func TestQuarryImpl_Nodes_isSnapshot(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	nodes := q.Nodes()
+
+	q.MustAddFactory("b", factoryOk())
+
+	assert.Len(t, nodes, 1)
+	assert.Len(t, q.Nodes(), 2)
+}
+
+func TestNodeKind_String(t *testing.T) {
+	assert.Equal(t, "factory", quarry.NodeFactory.String())
+	assert.Equal(t, "switch", quarry.NodeSwitch.String())
+	assert.Equal(t, "alias", quarry.NodeAlias.String())
+	assert.Equal(t, "NodeKind(7)", quarry.NodeKind(7).String())
+}
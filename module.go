@@ -0,0 +1,190 @@
+package quarry
+
+import "fmt"
+
+// Module groups factories and their dependencies under a prefix, so that
+// packages can register nodes without colliding with each other. A node named
+// user in a Module with the prefix userstorage is installed as userstorage.user.
+//
+// Within a Module, names of dependencies refer to the Module's own factories
+// first, and to nodes of the Quarry it is installed into otherwise. Factories
+// of the Module receive their dependencies under the names they were declared
+// with. Only the nodes a Module exports may be depended upon from outside of it.
+type Module struct {
+	prefix    string
+	factories []moduleFactory
+	edges     []moduleEdge
+	exports   []string
+	// declared holds the unqualified names of the Module's factories.
+	declared stringSet
+}
+
+type moduleFactory struct {
+	name    string
+	factory Factory
+	options []FactoryOption
+}
+
+type moduleEdge struct {
+	parent, dependsOn string
+	options           []EdgeOption
+}
+
+// NewModule creates a Module whose nodes are named with the given prefix.
+func NewModule(prefix string) *Module {
+	return &Module{
+		prefix:   prefix,
+		declared: newStringSet(),
+	}
+}
+
+// Prefix returns the prefix of the Module's node names.
+func (m *Module) Prefix() string {
+	return m.prefix
+}
+
+// Name returns the qualified name of one of the Module's nodes.
+func (m *Module) Name(name string) string {
+	return fmt.Sprintf("%s.%s", m.prefix, name)
+}
+
+// AddFactory declares a Factory in the Module.
+// Problems, such as duplicate names, are reported when the Module is installed.
+func (m *Module) AddFactory(name string, factory Factory, options ...FactoryOption) {
+	m.factories = append(m.factories, moduleFactory{name: name, factory: factory, options: options})
+	m.declared.Add(name)
+}
+
+// AddDependency declares that parent, a Factory of the Module, depends on
+// another node. See Quarry.AddDependency.
+func (m *Module) AddDependency(parent, dependsOn string, conditions ...Condition) {
+	m.AddEdge(parent, dependsOn, When(conditions...))
+}
+
+// AddEdge declares that parent, a Factory of the Module, depends on another
+// node. See Quarry.AddEdge.
+func (m *Module) AddEdge(parent, dependsOn string, options ...EdgeOption) {
+	m.edges = append(m.edges, moduleEdge{parent: parent, dependsOn: dependsOn, options: options})
+}
+
+// Export allows nodes of the Module to be depended upon from outside of it.
+func (m *Module) Export(names ...string) {
+	m.exports = append(m.exports, names...)
+}
+
+// resolve qualifies name if it refers to one of the Module's factories.
+func (m *Module) resolve(name string) string {
+	if m.declared.Contains(name) {
+		return m.Name(name)
+	}
+	return name
+}
+
+// qualifyPrerequisites is an EdgeOption that resolves the prerequisites of
+// the conditions of an edge declared in the Module. The conditions still
+// receive the prerequisites under the names they were declared with.
+func (m *Module) qualifyPrerequisites(e *edge) {
+	for _, g := range e.guards {
+		prerequisites := make([]string, len(g.prerequisites))
+		for i, prerequisite := range g.prerequisites {
+			prerequisites[i] = m.resolve(prerequisite)
+		}
+		g.keys = g.prerequisites
+		g.prerequisites = prerequisites
+	}
+}
+
+func (q *quarryImpl) MustInstall(m *Module) {
+	if err := q.Install(m); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) Install(m *Module) error {
+	exported := newStringSet()
+	for _, name := range m.exports {
+		if !m.declared.Contains(name) {
+			return fmt.Errorf("module %s exports %s, which it does not declare", m.prefix, name)
+		}
+		exported.Add(name)
+	}
+	for _, dependency := range m.edges {
+		if !m.declared.Contains(dependency.parent) {
+			return fmt.Errorf("module %s declares a dependency of %s, which it does not declare", m.prefix, dependency.parent)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var installed []string
+	var added, linked []moduleEdge
+	declared := newStringSet()
+	for _, f := range m.factories {
+		if _, ok := q.adjacency[m.Name(f.name)]; ok {
+			declared.Add(m.Name(f.name))
+		}
+	}
+	// rollback leaves the quarry as it was before the Module was installed.
+	rollback := func() {
+		for _, e := range added {
+			q.adjacency[e.parent].Remove(e.dependsOn)
+		}
+		for _, name := range installed {
+			if !declared.Contains(name) {
+				delete(q.adjacency, name)
+			}
+			delete(q.factories, name)
+		}
+		for _, e := range linked {
+			q.unlink(e.parent, e.dependsOn)
+		}
+	}
+
+	for _, f := range m.factories {
+		name := m.Name(f.name)
+		if err := q.addFactory(name, f.factory, f.options); err != nil {
+			rollback()
+			return err
+		}
+		installed = append(installed, name)
+		n := q.factories[name]
+		n.module = m.prefix
+		n.exported = exported.Contains(f.name)
+	}
+	for _, dependency := range m.edges {
+		parent, dependsOn := m.Name(dependency.parent), m.resolve(dependency.dependsOn)
+		// Dependencies on the Module's own factories are delivered under
+		// their unqualified names, unless the edge sets its own key.
+		options := []EdgeOption{As(dependency.dependsOn)}
+		options = append(options, dependency.options...)
+		options = append(options, m.qualifyPrerequisites)
+		wasLinked := newStringSet()
+		for name := range q.successors[parent] {
+			wasLinked.Add(name)
+		}
+		if err := q.addEdge(parent, dependsOn, options); err != nil {
+			rollback()
+			return err
+		}
+		added = append(added, moduleEdge{parent: parent, dependsOn: dependsOn})
+		for name := range q.successors[parent] {
+			if !wasLinked.Contains(name) {
+				linked = append(linked, moduleEdge{parent: parent, dependsOn: name})
+			}
+		}
+	}
+	return nil
+}
+
+// reachable returns true if parent may depend on dependsOn: either
+// dependsOn does not belong to a Module, or it is exported, or parent belongs
+// to the same Module.
+// The caller must hold mu.
+func (q *quarryImpl) reachable(parent, dependsOn string) bool {
+	n, _, ok := q.lookupLocked(dependsOn)
+	if !ok || n.module == "" || n.exported {
+		return true
+	}
+	p, _, ok := q.lookupLocked(parent)
+	return ok && p.module == n.module
+}
@@ -0,0 +1,150 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func userStorageModule() *quarry.Module {
+	m := quarry.NewModule("userstorage")
+	m.AddFactory("db", factoryValue("db"))
+	m.AddFactory("user", factoryDeps())
+	m.AddDependency("user", "db")
+	m.AddDependency("user", "config")
+	m.Export("user")
+	return m
+}
+
+func TestQuarryImpl_Install(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("config", factoryValue("config"))
+	q.MustAddFactory("db", factoryValue("global db"))
+	m := userStorageModule()
+
+	err := q.Install(m)
+	value, getErr := q.Get(context.Background(), nil, m.Name("user"))
+
+	assert.NoError(t, err)
+	assert.NoError(t, getErr)
+	assert.Equal(t, "userstorage.user", m.Name("user"))
+	assert.Equal(t, quarry.Dependencies{"db": "db", "config": "config"}, value)
+	assert.NoError(t, q.Validate())
+}
+
+func TestQuarryImpl_Install_qualifiesPrerequisites(t *testing.T) {
+	q := quarry.New()
+	m := quarry.NewModule("admin")
+	m.AddFactory("user", factoryValue("admin"))
+	m.AddFactory("dashboard", factoryOk())
+	m.AddFactory("root", factoryDeps())
+	m.AddEdge("root", "dashboard", quarry.WhenResolved(isAdmin, "user"))
+	q.MustInstall(m)
+
+	value, err := q.Get(context.Background(), nil, m.Name("root"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"dashboard": 0}, value)
+}
+
+func TestQuarryImpl_Install_collisionsAreNamespaced(t *testing.T) {
+	q := quarry.New()
+	a, b := quarry.NewModule("a"), quarry.NewModule("b")
+	a.AddFactory("user", factoryOk())
+	b.AddFactory("user", factoryOk())
+
+	errA, errB := q.Install(a), q.Install(b)
+
+	assert.NoError(t, errA)
+	assert.NoError(t, errB)
+	assert.True(t, q.Has("a.user"))
+	assert.True(t, q.Has("b.user"))
+}
+
+func TestQuarryImpl_Install_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		module func() *quarry.Module
+		err    string
+	}{
+		{"undeclared export", func() *quarry.Module {
+			m := quarry.NewModule("m")
+			m.Export("missing")
+			return m
+		}, "module m exports missing, which it does not declare"},
+		{"undeclared parent", func() *quarry.Module {
+			m := quarry.NewModule("m")
+			m.AddDependency("missing", "a")
+			return m
+		}, "module m declares a dependency of missing, which it does not declare"},
+		{"duplicate factory", func() *quarry.Module {
+			m := quarry.NewModule("m")
+			m.AddFactory("a", factoryOk())
+			m.AddFactory("a", factoryOk())
+			return m
+		}, "duplicate add of factory m.a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := quarry.New()
+
+			err := q.Install(test.module())
+
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestQuarryImpl_Install_failureLeavesQuarryUnchanged(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("config", factoryOk())
+	m := quarry.NewModule("m")
+	m.AddFactory("a", factoryDeps())
+	m.AddFactory("b", factoryDeps())
+	m.AddDependency("a", "config")
+	m.AddDependency("a", "b")
+	m.AddDependency("b", "a")
+
+	err := q.Install(m)
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.False(t, q.Has("m.a"))
+	assert.False(t, q.Has("m.b"))
+	assert.Empty(t, q.DependenciesOf("m.a"))
+	assert.Empty(t, q.DependentsOf("config"))
+	assert.NoError(t, q.Install(userStorageModule()))
+	assert.NoError(t, q.AddDependency("config", "m.a"))
+}
+
+func TestQuarryImpl_Validate_unexportedNode(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("config", factoryOk())
+	q.MustInstall(userStorageModule())
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddDependency("root", "userstorage.user")
+	q.MustAddDependency("root", "userstorage.db")
+
+	err := q.Validate()
+
+	var validationErr *quarry.ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.EqualError(t, err, "invalid quarry: factory userstorage.db is not exported by module userstorage, but is depended upon by root")
+}
+
+func TestQuarryImpl_Validate_unexportedNodeFromOtherModule(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("config", factoryOk())
+	q.MustInstall(userStorageModule())
+	m := quarry.NewModule("other")
+	m.AddFactory("root", factoryOk())
+	m.AddEdge("root", "userstorage.db", quarry.Optional())
+	q.MustInstall(m)
+
+	err := q.Validate()
+
+	assert.EqualError(t, err, "invalid quarry: factory userstorage.db is not exported by module userstorage, but is depended upon by other.root")
+}
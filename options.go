@@ -0,0 +1,117 @@
+package quarry
+
+import (
+	"context"
+	"time"
+)
+
+// FactoryOption configures how a Factory is executed.
+type FactoryOption func(n *node)
+
+// WithTimeout limits how long a Factory may run.
+// The Factory receives a Context with the timeout applied. If the timeout
+// passes before the Factory returns, the resolution fails with a
+// *TimeoutError, regardless of the deadline of the caller's Context.
+func WithTimeout(timeout time.Duration) FactoryOption {
+	return func(n *node) {
+		n.timeout = timeout
+	}
+}
+
+// NilOnTimeout delivers nil to dependents that depend on the Factory
+// conditionally, instead of failing the resolution, when the Factory exceeds
+// its timeout. Dependents with unconditional dependencies still fail.
+func NilOnTimeout() FactoryOption {
+	return func(n *node) {
+		n.nilOnTimeout = true
+	}
+}
+
+// node is a registered Factory and the options it was registered with.
+type node struct {
+	factory Factory
+
+	// timeout limits how long factory may run, when positive.
+	timeout time.Duration
+	// nilOnTimeout delivers nil to conditional dependents when factory times out.
+	nilOnTimeout bool
+	// retry describes how factory is retried when it fails, if at all.
+	retry *RetryPolicy
+	// breaker stops factory from being called after repeated failures, if set.
+	breaker *circuitBreaker
+	// fallback is called when factory fails, if set.
+	fallback Factory
+	// cases choose the node to resolve to instead of calling factory, for
+	// nodes registered with AddSwitch.
+	cases []SwitchCase
+	// alias is the name of the node this node stands for, for nodes
+	// registered with AddAlias.
+	alias string
+	// singleton is set for nodes registered with AsSingleton.
+	singleton bool
+	// module is the prefix of the Module the node was installed from, if any.
+	module string
+	// exported nodes of a Module may be depended upon from outside of it.
+	exported bool
+}
+
+func newNode(factory Factory, options []FactoryOption) *node {
+	n := &node{factory: factory}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// call executes the Factory according to the node's options, returning the
+// number of times the Factory was called.
+func (n *node) call(ctx context.Context, name string, params interface{}, deps Dependencies) (value interface{}, attempts int, err error) {
+	if n.breaker != nil {
+		if err := n.breaker.allow(); err != nil {
+			return nil, 0, err
+		}
+		defer func() {
+			n.breaker.record(ctx, err)
+		}()
+	}
+	for attempts = 1; ; attempts++ {
+		value, err = n.attempt(ctx, name, params, deps)
+		if err == nil || !n.retry.shouldRetry(attempts, err) || ctx.Err() != nil {
+			return value, attempts, err
+		}
+		if sleep(ctx, n.retry.backoff(attempts)) != nil {
+			return value, attempts, err
+		}
+	}
+}
+
+// attempt executes the Factory once, applying the node's timeout.
+func (n *node) attempt(ctx context.Context, name string, params interface{}, deps Dependencies) (interface{}, error) {
+	if n.timeout <= 0 {
+		return callFactory(ctx, name, n.factory, params, deps)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	type callResult struct {
+		value interface{}
+		err   error
+	}
+	// The Factory runs in its own goroutine so that the timeout is enforced
+	// even if the Factory ignores its Context.
+	done := make(chan callResult, 1)
+	go func() {
+		value, err := callFactory(timeoutCtx, name, n.factory, params, deps)
+		done <- callResult{value, err}
+	}()
+	var result callResult
+	select {
+	case result = <-done:
+	case <-timeoutCtx.Done():
+		result.err = timeoutCtx.Err()
+	}
+	if result.err != nil && timeoutCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return nil, &TimeoutError{Node: name, Timeout: n.timeout}
+	}
+	return result.value, result.err
+}
@@ -0,0 +1,92 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTimeout_factoryReceivesDeadline(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		_, ok := ctx.Deadline()
+		return ok, nil
+	}, quarry.WithTimeout(time.Second))
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, true, value)
+}
+
+func TestWithTimeout_exceededResultsInTimeoutError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("slow", factorySlow(time.Second), quarry.WithTimeout(10*time.Millisecond))
+	q.MustAddDependency("root", "slow")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var timeoutErr *quarry.TimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, "slow", timeoutErr.Node)
+	assert.Equal(t, 10*time.Millisecond, timeoutErr.Timeout)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "node slow exceeded its 10ms budget")
+}
+
+func TestWithTimeout_enforcedWhenFactoryIgnoresContext(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	}, quarry.WithTimeout(10*time.Millisecond))
+	start := time.Now()
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var timeoutErr *quarry.TimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestNilOnTimeout_conditionalDependencyIsNil(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryWithNonNilDeps(factoryOk(), "fast"))
+	q.MustAddFactory("fast", factoryOk())
+	q.MustAddFactory("slow", factorySlow(time.Second), quarry.WithTimeout(10*time.Millisecond), quarry.NilOnTimeout())
+	q.MustAddDependency("root", "fast")
+	q.MustAddDependency("root", "slow", func(interface{}) bool { return true })
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+}
+
+func TestNilOnTimeout_unconditionalDependencyFails(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("slow", factorySlow(time.Second), quarry.WithTimeout(10*time.Millisecond), quarry.NilOnTimeout())
+	q.MustAddDependency("root", "slow")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var timeoutErr *quarry.TimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+}
+
+// factorySlow creates a Factory that waits for a duration or until its Context is done.
+func factorySlow(d time.Duration) quarry.Factory {
+	return func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		select {
+		case <-time.After(d):
+			return 0, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
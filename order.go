@@ -0,0 +1,129 @@
+package quarry
+
+import "sort"
+
+// topoOrder maintains a topological order of a graph as edges are added, so
+// that adding an edge only searches the graph when the edge contradicts the
+// current order, and then only the region between its two endpoints.
+//
+// See Pearce and Kelly, "A Dynamic Topological Sort Algorithm for Directed
+// Acyclic Graphs".
+type topoOrder struct {
+	// index is the position of each node in the order. Parents come before
+	// the nodes they depend on.
+	index map[string]int
+	// first and last are the lowest and highest positions assigned so far.
+	first, last int
+}
+
+func newTopoOrder() *topoOrder {
+	return &topoOrder{
+		index: make(map[string]int),
+		last:  -1,
+	}
+}
+
+// insert updates the order for a new edge from parent to dependsOn.
+// If the edge would create a cycle, the order is left unchanged and the names
+// of the nodes forming the cycle are returned, beginning and ending with parent.
+// successors and dependents describe the graph before the edge is added.
+func (t *topoOrder) insert(successors, dependents map[string]stringSet, parent, dependsOn string) []string {
+	if parent == dependsOn {
+		return []string{parent, parent}
+	}
+	// New nodes have no edges, so placing a new parent before every other
+	// node, or a new dependency after every other node, never contradicts
+	// the order.
+	parentIndex, ok := t.index[parent]
+	if !ok {
+		t.first--
+		parentIndex = t.first
+		t.index[parent] = parentIndex
+	}
+	dependsOnIndex, ok := t.index[dependsOn]
+	if !ok {
+		t.last++
+		dependsOnIndex = t.last
+		t.index[dependsOn] = dependsOnIndex
+	}
+	if parentIndex < dependsOnIndex {
+		return nil
+	}
+
+	// Find everything dependsOn reaches that is ordered no later than parent.
+	// Reaching parent means the edge closes a cycle.
+	forward, via := search(t, successors, dependsOn, func(index int) bool {
+		return index <= parentIndex
+	})
+	if _, ok := via[parent]; ok {
+		return cyclePath(via, parent, dependsOn)
+	}
+
+	// Find everything that reaches parent that is ordered no earlier than dependsOn.
+	backward, _ := search(t, dependents, parent, func(index int) bool {
+		return index >= dependsOnIndex
+	})
+
+	// Reassign the positions held by both regions so that everything reaching
+	// parent comes before everything dependsOn reaches.
+	t.sortByIndex(forward)
+	t.sortByIndex(backward)
+	nodes := append(backward, forward...)
+	positions := make([]int, len(nodes))
+	for i, name := range nodes {
+		positions[i] = t.index[name]
+	}
+	sort.Ints(positions)
+	for i, name := range nodes {
+		t.index[name] = positions[i]
+	}
+	return nil
+}
+
+// cyclePath returns the names of the nodes forming the cycle closed by an
+// edge from parent to dependsOn, beginning and ending with parent, given the
+// node each node was reached from when searching from dependsOn to parent.
+func cyclePath(via map[string]string, parent, dependsOn string) []string {
+	path := []string{parent}
+	for name := parent; name != dependsOn; {
+		name = via[name]
+		path = append(path, name)
+	}
+	// path runs from parent back to dependsOn; reverse it to follow the edges.
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return append([]string{parent}, path...)
+}
+
+// search performs a DFS of graph from start, following neighbors whose
+// positions are within bounds. It returns the nodes visited and, for each
+// visited node other than start, the node it was reached from.
+func search[M ~map[string]V, V any](t *topoOrder, graph map[string]M, start string, within func(int) bool) ([]string, map[string]string) {
+	visited := []string{start}
+	via := map[string]string{start: ""}
+	stack := []string{start}
+	for len(stack) > 0 {
+		name := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for neighbor := range graph[name] {
+			if _, seen := via[neighbor]; seen {
+				continue
+			}
+			if index, ok := t.index[neighbor]; !ok || !within(index) {
+				continue
+			}
+			via[neighbor] = name
+			visited = append(visited, neighbor)
+			stack = append(stack, neighbor)
+		}
+	}
+	return visited, via
+}
+
+// sortByIndex sorts names by their position in the order.
+func (t *topoOrder) sortByIndex(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		return t.index[names[i]] < t.index[names[j]]
+	})
+}
@@ -0,0 +1,81 @@
+package quarry
+
+import (
+	"fmt"
+	"sync"
+)
+
+func (q *quarryImpl) Override(name string, factory Factory) (restore func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.frozen.Load() {
+		panic(fmt.Sprintf("cannot override factory %s in a frozen quarry", name))
+	}
+	previous, local := q.factories[name]
+	_, declared := q.adjacency[name]
+	existing, _, ok := q.lookupLocked(name)
+	if !ok {
+		panic(fmt.Sprintf("cannot override factory %s, which does not exist", name))
+	}
+	switch {
+	case existing.isSwitch():
+		panic(fmt.Sprintf("cannot override switch %s, override its targets instead", name))
+	case existing.isAlias():
+		panic(fmt.Sprintf("cannot override alias %s, override its target instead", name))
+	}
+	overridden := *existing
+	overridden.factory = factory
+	if existing.singleton {
+		overridden.factory = asSingleton(factory)
+	}
+	if existing.breaker != nil {
+		// The failures of the replaced Factory do not count against the new one.
+		overridden.breaker = newCircuitBreaker(existing.breaker.threshold, existing.breaker.cooldown)
+	}
+	q.factories[name] = &overridden
+	if !local && !declared {
+		// Overriding a node of a parent in a child: keep the parent's dependencies.
+		_, edges, _ := q.parent.node(name)
+		if edges != nil {
+			q.adjacency[name] = edges.clone()
+		}
+	}
+	q.bump(name)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if q.frozen.Load() {
+				panic(fmt.Sprintf("cannot restore factory %s in a frozen quarry", name))
+			}
+			if local {
+				q.factories[name] = previous
+			} else {
+				delete(q.factories, name)
+				if !declared {
+					delete(q.adjacency, name)
+				}
+			}
+			q.bump(name)
+		})
+	}
+}
+
+// bump starts a new epoch for the named node, so that Singletons that
+// transitively depend on it are created anew.
+// The caller must hold mu.
+func (q *quarryImpl) bump(name string) {
+	q.epochs[name] = epochs.Add(1)
+}
+
+// epoch returns the epoch of the named node, or zero if it was never overridden.
+func (q *quarryImpl) epoch(name string) uint64 {
+	if q.frozen.Load() {
+		return q.epochs[name]
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.epochs[name]
+}
@@ -0,0 +1,145 @@
+package quarry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarryImpl_Override(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("service", factoryDeps())
+	q.MustAddFactory("userStorage", factoryValue("sql"))
+	q.MustAddFactory("db", factoryValue("db"))
+	q.MustAddDependency("service", "userStorage")
+	q.MustAddDependency("userStorage", "db")
+
+	restore := q.Override("userStorage", factoryDeps())
+	overridden, overriddenErr := q.Get(context.Background(), nil, "service")
+	restore()
+	restored, restoredErr := q.Get(context.Background(), nil, "service")
+
+	assert.NoError(t, overriddenErr)
+	assert.NoError(t, restoredErr)
+	assert.Equal(t, quarry.Dependencies{"userStorage": quarry.Dependencies{"db": "db"}}, overridden)
+	assert.Equal(t, quarry.Dependencies{"userStorage": "sql"}, restored)
+}
+
+func TestQuarryImpl_Override_invalidatesSingletons(t *testing.T) {
+	q := quarry.New()
+	dbCount, db := singletonCounter()
+	serviceCount, service := singletonCounter()
+	q.MustAddFactory("db", db)
+	q.MustAddFactory("userStorage", factoryValue("sql"))
+	q.MustAddFactory("service", service)
+	q.MustAddDependency("service", "userStorage")
+	q.MustAddDependency("service", "db")
+
+	before := q.MustGetAll(context.Background(), nil, "db", "service")
+	restore := q.Override("userStorage", factoryValue("fake"))
+	overridden := q.MustGetAll(context.Background(), nil, "db", "service")
+	overriddenAgain := q.MustGetAll(context.Background(), nil, "db", "service")
+	restore()
+	restored := q.MustGetAll(context.Background(), nil, "db", "service")
+
+	assert.Equal(t, quarry.Dependencies{"db": int32(1), "service": int32(1)}, before)
+	assert.Equal(t, quarry.Dependencies{"db": int32(1), "service": int32(2)}, overridden)
+	assert.Equal(t, overridden, overriddenAgain)
+	assert.Equal(t, quarry.Dependencies{"db": int32(1), "service": int32(3)}, restored)
+	assert.Equal(t, int32(1), *dbCount)
+	assert.Equal(t, int32(3), *serviceCount)
+}
+
+func TestQuarryImpl_Override_inChildKeepsParentDependencies(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("userStorage", factoryValue("sql"))
+	q.MustAddFactory("db", factoryValue("db"))
+	q.MustAddDependency("userStorage", "db")
+	child := q.Child()
+
+	restore := child.Override("userStorage", factoryDeps())
+	overridden, overriddenErr := child.Get(context.Background(), nil, "userStorage")
+	parent, parentErr := q.Get(context.Background(), nil, "userStorage")
+	restore()
+	restored, restoredErr := child.Get(context.Background(), nil, "userStorage")
+
+	assert.NoError(t, overriddenErr)
+	assert.NoError(t, parentErr)
+	assert.NoError(t, restoredErr)
+	assert.Equal(t, quarry.Dependencies{"db": "db"}, overridden)
+	assert.Equal(t, "sql", parent)
+	assert.Equal(t, "sql", restored)
+}
+
+func TestQuarryImpl_Override_restoreIsIdempotent(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryValue("original"))
+	restoreFirst := q.Override("a", factoryValue("first"))
+	restoreSecond := q.Override("a", factoryValue("second"))
+
+	restoreSecond()
+	restoreSecond()
+	value := q.MustGet(context.Background(), nil, "a")
+	restoreFirst()
+	original := q.MustGet(context.Background(), nil, "a")
+
+	assert.Equal(t, "first", value)
+	assert.Equal(t, "original", original)
+}
+
+func TestQuarryImpl_Override_keepsOptions(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("userStorage", factoryValue("sql"), quarry.WithTimeout(10*time.Millisecond))
+	q.MustAddFallback("userStorage", factoryValue("cache"))
+
+	restore := q.Override("userStorage", factorySlow(time.Second))
+	result, err := q.Resolve(context.Background(), nil, "userStorage")
+	restore()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cache", result.Value)
+	assert.Equal(t, []string{"userStorage"}, result.Fallbacks)
+}
+
+func TestQuarryImpl_Override_keepsSingleton(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("db", factoryValue("sql"), quarry.AsSingleton())
+	count, counter := factoryCounter()
+
+	restore := q.Override("db", counter)
+	first := q.MustGet(context.Background(), nil, "db")
+	second := q.MustGet(context.Background(), nil, "db")
+	nodes := q.Nodes()
+	restore()
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), *count)
+	assert.Equal(t, []quarry.NodeInfo{{Name: "db", Singleton: true}}, nodes)
+}
+
+func TestQuarryImpl_Override_panics(t *testing.T) {
+	missing := quarry.New()
+	frozen := quarry.New()
+	frozen.MustAddFactory("a", factoryOk())
+	frozen.MustFreeze()
+	switched := quarry.New()
+	switched.MustAddFactory("mem", factoryOk())
+	switched.MustAddSwitch("s", quarry.Default("mem"))
+	switched.MustAddAlias("alias", "mem")
+
+	assert.PanicsWithValue(t, "cannot override factory a, which does not exist", func() {
+		missing.Override("a", factoryOk())
+	})
+	assert.PanicsWithValue(t, "cannot override factory a in a frozen quarry", func() {
+		frozen.Override("a", factoryOk())
+	})
+	assert.PanicsWithValue(t, "cannot override switch s, override its targets instead", func() {
+		switched.Override("s", factoryOk())
+	})
+	assert.PanicsWithValue(t, "cannot override alias alias, override its target instead", func() {
+		switched.Override("alias", factoryOk())
+	})
+}
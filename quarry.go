@@ -2,8 +2,13 @@ package quarry
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Quarry is a dependency graph to fulfill requirements that can provided
@@ -11,9 +16,22 @@ import (
 type Quarry interface {
 	// AddFactory registers a Factory by name.
 	// Names must be unique.
-	AddFactory(name string, factory Factory) error
+	// FactoryOptions control how the Factory is executed.
+	AddFactory(name string, factory Factory, options ...FactoryOption) error
 	// MustAddFactory panics if AddFactory fails.
-	MustAddFactory(name string, factory Factory)
+	MustAddFactory(name string, factory Factory, options ...FactoryOption)
+
+	// AddConstructor registers a function as a Factory, deriving its dependencies
+	// from the function's signature.
+	// The function may accept any number of parameters and must return a value
+	// and an optional error. Parameters of type context.Context receive the
+	// resolution's Context; all other parameters are dependencies.
+	// Dependencies are named by dependsOn, in parameter order, or, when dependsOn
+	// is empty, matched by type to factories registered earlier with
+	// AddConstructor or AddTypedFactory.
+	AddConstructor(name string, fn interface{}, dependsOn ...string) error
+	// MustAddConstructor panics if AddConstructor fails.
+	MustAddConstructor(name string, fn interface{}, dependsOn ...string)
 
 	// AddDependency links two factory together as dependencies.
 	// By default, dependencies are always fulfilled, but when conditions are present
@@ -24,99 +42,329 @@ type Quarry interface {
 	// MustAddDependency panics if AddDependency fails.
 	MustAddDependency(parent, dependsOn string, conditions ...Condition)
 
+	// AddEdge is like AddDependency, but accepts EdgeOptions that control how
+	// the dependency is fulfilled.
+	AddEdge(parent, dependsOn string, options ...EdgeOption) error
+	// MustAddEdge panics if AddEdge fails.
+	MustAddEdge(parent, dependsOn string, options ...EdgeOption)
+
+	// AddFallback registers a Factory to use when the Factory registered
+	// under name fails or times out. The fallback receives the same parameters
+	// and dependencies, and its value is handed to dependents instead of
+	// failing the resolution. Resolve reports when a fallback was used.
+	AddFallback(name string, fallback Factory) error
+	// MustAddFallback panics if AddFallback fails.
+	MustAddFallback(name string, fallback Factory)
+
+	// AddSwitch registers a node that resolves to the value of one of several
+	// nodes, such as memoryUserStorage or sqlUserStorage for userStorage.
+	// The target of the first case whose Predicate is met is chosen, or the
+	// target of the Default case if none are. Only the chosen target is built.
+	// Switches cannot have dependencies of their own.
+	AddSwitch(name string, cases ...SwitchCase) error
+	// MustAddSwitch panics if AddSwitch fails.
+	MustAddSwitch(name string, cases ...SwitchCase)
+
+	// Install adds the factories and dependencies of a Module, naming each of
+	// its factories with the Module's prefix. If Install fails, none of the
+	// Module is installed.
+	Install(m *Module) error
+	// MustInstall panics if Install fails.
+	MustInstall(m *Module)
+
+	// Child creates a Quarry that looks up nodes in this Quarry unless they are
+	// overridden in the child, by registering their Factory or declaring their
+	// dependencies in it. Overridden nodes only use the dependencies declared
+	// in the child.
+	// Singletons are shared with this Quarry, except those of overridden nodes
+	// and of nodes that transitively depend on them, which are created anew
+	// for the child.
+	Child() Quarry
+
+	// Override replaces the Factory registered under name, keeping its
+	// dependencies, fallback and FactoryOptions, until restore is called.
+	// Singletons of the node and of nodes that transitively depend on it are
+	// created anew after both.
+	// Override is meant for tests, see quarrytest.Override. It panics if the
+	// quarry is frozen, no Factory is registered under name, or name is a
+	// switch or alias.
+	Override(name string, factory Factory) (restore func())
+
+	// AddAlias lets the node named target also be referred to as alias.
+	// Within a resolution, the node is only created once no matter which of
+	// its names it is fetched by. Aliases cannot have dependencies of their own.
+	AddAlias(alias, target string) error
+	// MustAddAlias panics if AddAlias fails.
+	MustAddAlias(alias, target string)
+
 	// Get will fetch an object by name using the parameters provided.
 	// If any Factories return an error or the Context is done, the first
 	// error encountered will be returned.
 	Get(ctx context.Context, params interface{}, name string) (interface{}, error)
 	// MustGet panics if Get fails.
 	MustGet(ctx context.Context, params interface{}, name string) interface{}
+
+	// Resolve is like Get, but also reports how the object was created.
+	Resolve(ctx context.Context, params interface{}, name string) (*Result, error)
+
+	// GetAll will fetch several objects by name in a single resolution, so that
+	// dependencies they have in common are only created once.
+	// If any Factories return an error or the Context is done, the first
+	// error encountered will be returned.
+	GetAll(ctx context.Context, params interface{}, names ...string) (Dependencies, error)
+	// MustGetAll panics if GetAll fails.
+	MustGetAll(ctx context.Context, params interface{}, names ...string) Dependencies
+
+	// Has returns true when a Factory is registered with the given name.
+	Has(name string) bool
+
+	// CircuitStates returns the state of the circuit breaker of every Factory
+	// registered WithCircuitBreaker, by name.
+	CircuitStates() map[string]CircuitState
+
+	// Nodes describes every node registered in the Quarry, or inherited from
+	// its parents, sorted by name.
+	// Like the other methods describing the graph, it returns a snapshot that
+	// later registrations do not change.
+	Nodes() []NodeInfo
+	// DependenciesOf describes the dependencies of the named node, sorted by
+	// the names of the nodes depended upon, or the targets of a switch or alias.
+	DependenciesOf(name string) []EdgeInfo
+	// DependentsOf describes the dependencies on the named node, sorted by the
+	// names of their parents.
+	DependentsOf(name string) []EdgeInfo
+	// TopologicalOrder returns the names of the registered nodes, ordered so
+	// that every node comes after the nodes it may need to resolve.
+	TopologicalOrder() []string
+	// Roots returns the names of the registered nodes that no node may need
+	// to resolve, sorted.
+	Roots() []string
+	// Leaves returns the names of the registered nodes that do not need to
+	// resolve any other node, sorted.
+	Leaves() []string
+
+	// Validate checks the graph for dependencies on Factories that do not exist,
+	// reporting every problem found in a single *ValidationError.
+	Validate() error
+	// Freeze validates the graph and prevents any further Factories or
+	// dependencies from being added. Once frozen, resolutions no longer need
+	// to lock the graph.
+	// Freeze is intended to be called once all registrations are complete,
+	// such as at the top of main.
+	Freeze() error
+	// MustFreeze panics if Freeze fails.
+	MustFreeze()
 }
 
 // New creates a new Quarry.
 func New() Quarry {
-	return quarryImpl{
-		adjacency: make(map[string]conditionMap),
-		factories: make(map[string]Factory),
+	return &quarryImpl{
+		adjacency:  make(map[string]edgeMap),
+		successors: make(map[string]stringSet),
+		dependents: make(map[string]stringSet),
+		order:      newTopoOrder(),
+		factories:  make(map[string]*node),
+		types:      make(map[string]reflect.Type),
+		epochs:     make(map[string]uint64),
 	}
 }
 
 // quarryImpl is the default implementation of Quarry.
 type quarryImpl struct {
-	// adjacency is a map of names of Factories to a set of names of
-	// Factories the parent Factory depends on.
-	adjacency map[string]conditionMap
+	// mu guards the graph while it is being built.
+	mu sync.RWMutex
+
+	// frozen is set once the graph can no longer change, at which point
+	// reads no longer acquire mu.
+	frozen atomic.Bool
+
+	// adjacency is a map of names of Factories to the names of the
+	// Factories the parent Factory depends on, and how it depends on them.
+	adjacency map[string]edgeMap
+
+	// successors is a map of names of Factories to the names of every
+	// Factory they may need to resolve: their dependencies and the
+	// prerequisites of the conditions on them.
+	successors map[string]stringSet
+
+	// dependents is the reverse of successors: a map of names of Factories
+	// to a set of names of Factories that may need to resolve them.
+	dependents map[string]stringSet
 
-	// factories is a map of names of Factories to Factories.
-	factories map[string]Factory
+	// order is a topological order of successors, used to detect cycles.
+	// Children do not maintain an order, see findCycle.
+	order *topoOrder
+
+	// factories is a map of names of Factories to Factories and their options.
+	factories map[string]*node
+
+	// epochs holds the epoch of each node changed by Override.
+	epochs map[string]uint64
+
+	// parent is the quarry this quarry was created from with Child, if any.
+	parent *quarryImpl
+	// depth is the number of parents of this quarry.
+	depth int
+
+	// singletonsMu guards singletons, which Factories use while resolving.
+	singletonsMu sync.Mutex
+	// singletons holds the values of Singletons scoped to this quarry, see scope.
+	singletons map[*singleton]*singletonValue
+
+	// types is a map of names of Factories to the type of value they produce,
+	// when known.
+	types map[string]reflect.Type
 }
 
-func (q quarryImpl) MustAddFactory(name string, factory Factory) {
-	if err := q.AddFactory(name, factory); err != nil {
+func (q *quarryImpl) MustAddFactory(name string, factory Factory, options ...FactoryOption) {
+	if err := q.AddFactory(name, factory, options...); err != nil {
 		panic(err)
 	}
 }
 
-func (q quarryImpl) AddFactory(name string, factory Factory) error {
+func (q *quarryImpl) AddFactory(name string, factory Factory, options ...FactoryOption) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.addFactory(name, factory, options)
+}
+
+// addFactory registers a Factory by name.
+// The caller must hold mu.
+func (q *quarryImpl) addFactory(name string, factory Factory, options []FactoryOption) error {
+	if q.frozen.Load() {
+		return fmt.Errorf("cannot add factory %s to a frozen quarry", name)
+	}
 	_, exists := q.factories[name]
 	if exists {
 		return fmt.Errorf("duplicate add of factory %s", name)
 	}
-	q.factories[name] = factory
+	q.factories[name] = newNode(factory, options)
 	return nil
 }
 
-func (q quarryImpl) MustAddDependency(parent, dependsOn string, conditions ...Condition) {
+func (q *quarryImpl) MustAddFallback(name string, fallback Factory) {
+	if err := q.AddFallback(name, fallback); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) AddFallback(name string, fallback Factory) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.frozen.Load() {
+		return fmt.Errorf("cannot add fallback for %s to a frozen quarry", name)
+	}
+	n, exists := q.factories[name]
+	if !exists {
+		return fmt.Errorf("cannot add fallback for factory %s, which does not exist", name)
+	}
+	if n.isSwitch() {
+		return fmt.Errorf("cannot add fallback for switch %s", name)
+	}
+	if n.isAlias() {
+		return fmt.Errorf("cannot add fallback for alias %s", name)
+	}
+	if n.fallback != nil {
+		return fmt.Errorf("duplicate add of fallback for %s", name)
+	}
+	n.fallback = fallback
+	return nil
+}
+
+func (q *quarryImpl) MustAddDependency(parent, dependsOn string, conditions ...Condition) {
 	if err := q.AddDependency(parent, dependsOn, conditions...); err != nil {
 		panic(err)
 	}
 }
 
-func (q quarryImpl) AddDependency(parent, dependsOn string, conditions ...Condition) error {
-	set, ok := q.adjacency[parent]
+func (q *quarryImpl) AddDependency(parent, dependsOn string, conditions ...Condition) error {
+	return q.AddEdge(parent, dependsOn, When(conditions...))
+}
+
+func (q *quarryImpl) MustAddEdge(parent, dependsOn string, options ...EdgeOption) {
+	if err := q.AddEdge(parent, dependsOn, options...); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) AddEdge(parent, dependsOn string, options ...EdgeOption) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.addEdge(parent, dependsOn, options)
+}
+
+// addEdge declares that parent depends on dependsOn.
+// The caller must hold mu.
+func (q *quarryImpl) addEdge(parent, dependsOn string, options []EdgeOption) error {
+	if q.frozen.Load() {
+		return fmt.Errorf("cannot add dependency on %s to %s in a frozen quarry", parent, dependsOn)
+	}
+	edges, ok := q.adjacency[parent]
 	if !ok {
-		set = newConditionMap()
-		q.adjacency[parent] = set
+		edges = newEdgeMap()
 	}
-	if set.Contains(dependsOn) {
+	if edges.Contains(dependsOn) {
 		return fmt.Errorf("duplicate add of dependency on %s to %s", parent, dependsOn)
 	}
-	set.Add(dependsOn, conditions...)
-	return q.checkCycles(parent, dependsOn)
-}
-
-// checkCycles will check the graph for cycles.
-func (q quarryImpl) checkCycles(parent, dependsOn string) error {
-	visited := newStringSet()
-	stack := newStringSet()
-	for name := range q.adjacency {
-		if !visited.Contains(name) {
-			if q.checkCyclesHelper(visited, stack, name) {
-				return fmt.Errorf("depedending %s on %s creates a cycle", parent, dependsOn)
+	if n, _, ok := q.lookupLocked(parent); ok {
+		switch {
+		case n.isSwitch():
+			return fmt.Errorf("switch %s cannot have dependencies", parent)
+		case n.isAlias():
+			return fmt.Errorf("alias %s cannot have dependencies", parent)
+		}
+	}
+	e := newEdge(options)
+	key := e.key(dependsOn)
+	for existing, existingEdge := range edges {
+		if existingEdge.key(existing) == key {
+			return fmt.Errorf("dependency of %s on %s uses key %s, which is already used by %s", parent, dependsOn, key, existing)
+		}
+	}
+	var linked []string
+	for _, name := range e.requires(dependsOn) {
+		if q.successors[parent].Contains(name) {
+			continue
+		}
+		if path := q.findCycle(parent, name); path != nil {
+			for _, name := range linked {
+				q.unlink(parent, name)
 			}
+			return &CycleError{Parent: parent, DependsOn: name, Path: path}
 		}
+		q.link(parent, name)
+		linked = append(linked, name)
 	}
+	edges.Add(dependsOn, e)
+	q.adjacency[parent] = edges
 	return nil
 }
 
-// checkCyclesHelper performs a recursive DFS to detect cycles.
-func (q quarryImpl) checkCyclesHelper(visited, stack stringSet, name string) bool {
-	visited.Add(name)
-	stack.Add(name)
-	neighbors := q.adjacency[name]
-	for neighbor := range neighbors {
-		if !visited.Contains(neighbor) {
-			if q.checkCyclesHelper(visited, stack, neighbor) {
-				return true
-			}
-		} else if stack.Contains(neighbor) {
-			return true
-		}
+// link records that parent may need to resolve dependsOn.
+// The caller must hold mu.
+func (q *quarryImpl) link(parent, dependsOn string) {
+	successors, ok := q.successors[parent]
+	if !ok {
+		successors = newStringSet()
+		q.successors[parent] = successors
+	}
+	successors.Add(dependsOn)
+	dependents, ok := q.dependents[dependsOn]
+	if !ok {
+		dependents = newStringSet()
+		q.dependents[dependsOn] = dependents
 	}
-	stack.Remove(name)
-	return false
+	dependents.Add(parent)
+}
+
+// unlink reverts link.
+// The caller must hold mu.
+func (q *quarryImpl) unlink(parent, dependsOn string) {
+	q.successors[parent].Remove(dependsOn)
+	q.dependents[dependsOn].Remove(parent)
 }
 
-func (q quarryImpl) MustGet(ctx context.Context, params interface{}, name string) interface{} {
+func (q *quarryImpl) MustGet(ctx context.Context, params interface{}, name string) interface{} {
 	result, err := q.Get(ctx, params, name)
 	if err != nil {
 		panic(err)
@@ -124,125 +372,435 @@ func (q quarryImpl) MustGet(ctx context.Context, params interface{}, name string
 	return result
 }
 
-func (q quarryImpl) Get(ctx context.Context, params interface{}, name string) (interface{}, error) {
+func (q *quarryImpl) Get(ctx context.Context, params interface{}, name string) (interface{}, error) {
+	result, err := q.Resolve(ctx, params, name)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+func (q *quarryImpl) Resolve(ctx context.Context, params interface{}, name string) (*Result, error) {
 	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, []string{name})
+	}
+	ctx, cancelFunc := context.WithCancelCause(ctx)
+	once := newOnceController(q)
+	once.require(name)
+	value, err := once.getOnce(ctx, cancelFunc, params, nil, name)
+	if err != nil {
+		cancelFunc(err)
 		return nil, err
 	}
-	ctx, cancelFunc := context.WithCancel(ctx)
-	once := &onceController{
-		q:     q,
-		onces: make(map[string]*onceDelegate),
+	return once.result(value), nil
+}
+
+func (q *quarryImpl) MustGetAll(ctx context.Context, params interface{}, names ...string) Dependencies {
+	results, err := q.GetAll(ctx, params, names...)
+	if err != nil {
+		panic(err)
 	}
-	return once.getOnce(ctx, cancelFunc, params, "", name)
+	return results
+}
+
+func (q *quarryImpl) GetAll(ctx context.Context, params interface{}, names ...string) (Dependencies, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(ctx, nil)
+	}
+	ctx, cancelFunc := context.WithCancelCause(ctx)
+	once := newOnceController(q)
+	roots := newEdgeMap()
+	for _, name := range names {
+		roots.Add(name, newEdge(nil))
+	}
+	values, _, err := once.getDependencies(ctx, cancelFunc, params, roots, nil)
+	return values, err
+}
+
+func (q *quarryImpl) Has(name string) bool {
+	_, _, ok := q.node(name)
+	return ok
+}
+
+// node looks up the Factory registered under name and its dependencies.
+// Until the graph is frozen, the dependencies are a copy that is safe to
+// use while other goroutines register more of the graph.
+func (q *quarryImpl) node(name string) (n *node, edges edgeMap, ok bool) {
+	if q.frozen.Load() {
+		return q.lookupLocked(name)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	n, edges, ok = q.lookupLocked(name)
+	return n, edges.clone(), ok
 }
 
 type onceController struct {
-	q     quarryImpl
+	q     *quarryImpl
 	m     sync.Mutex
 	rw    sync.RWMutex
 	onces map[string]*onceDelegate
+
+	// required holds the names of nodes whose failure fails the resolution,
+	// because they are reached from a root without passing through an
+	// optional dependency.
+	required stringSet
+
+	// fallbacks holds the names of nodes whose fallback Factory was used.
+	fallbacks []string
+
+	// scoped is true when factories may need a scope, see quarryImpl.scoped.
+	scoped bool
+	// scopes holds the scope of each node, when scoped.
+	scopes map[string]scope
+}
+
+func newOnceController(q *quarryImpl) *onceController {
+	return &onceController{
+		q:        q,
+		onces:    make(map[string]*onceDelegate),
+		required: newStringSet(),
+		scoped:   q.scoped(),
+		scopes:   make(map[string]scope),
+	}
+}
+
+// require records that the named node's failure fails the resolution.
+func (o *onceController) require(name string) {
+	name = o.q.canonical(name)
+	o.m.Lock()
+	o.required.Add(name)
+	o.m.Unlock()
+}
+
+// isRequired returns true if the named node's failure fails the resolution.
+func (o *onceController) isRequired(name string) bool {
+	o.m.Lock()
+	defer o.m.Unlock()
+	return o.required.Contains(name)
+}
+
+// addFallback records that the named node used its fallback Factory.
+func (o *onceController) addFallback(name string) {
+	o.m.Lock()
+	o.fallbacks = append(o.fallbacks, name)
+	o.m.Unlock()
+}
+
+// result creates a Result for a completed resolution.
+func (o *onceController) result(value interface{}) *Result {
+	o.m.Lock()
+	defer o.m.Unlock()
+	fallbacks := append([]string(nil), o.fallbacks...)
+	sort.Strings(fallbacks)
+	return &Result{
+		Value:     value,
+		Fallbacks: fallbacks,
+	}
 }
 
 type onceDelegate struct {
-	result interface{}
-	err    error
-	f      func() (interface{}, error)
-	once   sync.Once
+	result  interface{}
+	err     error
+	f       func() (interface{}, error)
+	started atomic.Bool
+	done    chan struct{}
 }
 
 func newOnceDelegate(f func() (interface{}, error)) *onceDelegate {
-	return &onceDelegate{f: f}
+	return &onceDelegate{f: f, done: make(chan struct{})}
 }
 
-func (o *onceDelegate) Do() (interface{}, error) {
-	o.once.Do(func() {
+// Do calls f if no one has yet, or waits for the first call to finish or the
+// Context to be done. Waiting on the Context keeps concurrent resolutions that
+// depend on each other from blocking forever.
+func (o *onceDelegate) Do(ctx context.Context, path []string) (interface{}, error) {
+	if o.started.CompareAndSwap(false, true) {
+		defer close(o.done)
 		o.result, o.err = o.f()
-	})
-	return o.result, o.err
+		return o.result, o.err
+	}
+	select {
+	case <-o.done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, contextError(ctx, path)
+	}
 }
 
-func (o *onceController) getOnce(ctx context.Context, cancelFunc func(), params interface{}, parent, name string) (interface{}, error) {
+// getOnce fetches an object at most once per resolution.
+// path is the chain of names that led to this object, from the requested root.
+// Aliases are resolved first, so that an object and its aliases are shared.
+func (o *onceController) getOnce(ctx context.Context, cancelFunc context.CancelCauseFunc, params interface{}, path []string, name string) (interface{}, error) {
+	name = o.q.canonical(name)
+	for i, visited := range path {
+		if visited == name {
+			parent := path[len(path)-1]
+			cycle := append([]string{parent}, path[i:]...)
+			return nil, &CycleError{Parent: parent, DependsOn: name, Path: cycle}
+		}
+	}
+	namePath := make([]string, len(path), len(path)+1)
+	copy(namePath, path)
+	namePath = append(namePath, name)
 	o.m.Lock()
 	delegate, ok := o.onces[name]
 	if !ok {
 		delegate = newOnceDelegate(func() (interface{}, error) {
-			return o.getHelper(ctx, cancelFunc, params, parent, name)
+			return o.getHelper(ctx, cancelFunc, params, namePath)
 		})
 		o.onces[name] = delegate
 	}
 	o.m.Unlock()
-	return delegate.Do()
+	return delegate.Do(ctx, namePath)
 }
 
 // getHelper will fetch an object, resolving dependencies, until an error occurs or the Context is done.
-func (o *onceController) getHelper(ctx context.Context, cancelFunc func(), params interface{}, parent, name string) (interface{}, error) {
-	factory, factoryExists := o.q.factories[name]
+// The object fetched is the last name in path.
+// Failures are returned to the dependents, which decide whether to abort the resolution.
+func (o *onceController) getHelper(ctx context.Context, cancelFunc context.CancelCauseFunc, params interface{}, path []string) (interface{}, error) {
+	name := path[len(path)-1]
+	n, edges, factoryExists := o.q.node(name)
 	if !factoryExists {
-		if parent == "" {
-			return abort(cancelFunc, fmt.Errorf("factory %s does not exist", name))
+		var err error
+		if len(path) == 1 {
+			err = fmt.Errorf("factory %s does not exist", name)
 		} else {
-			return abort(cancelFunc, fmt.Errorf("factory %s, depended upon by %s, does not exist", name, parent))
+			err = fmt.Errorf("factory %s, depended upon by %s, does not exist", name, path[len(path)-2])
 		}
+		return nil, newResolutionError(path, FactoryMissing, err)
+	}
+	if n.isSwitch() {
+		return o.getSwitch(ctx, cancelFunc, params, path, n)
+	}
+	if n.isAlias() {
+		// canonical only stops at an alias when aliases form a cycle.
+		return nil, o.q.aliasCycle(name)
 	}
-
-	depConditions, hasDeps := o.q.adjacency[name]
 
 	var deps Dependencies
-	if hasDeps {
-		if thisDeps, depsErr := o.getDependencies(ctx, cancelFunc, params, depConditions, parent, name); depsErr != nil {
-			return abort(cancelFunc, depsErr)
+	factoryCtx := ctx
+	if edges != nil {
+		if thisDeps, info, depsErr := o.getDependencies(ctx, cancelFunc, params, edges, path); depsErr != nil {
+			return nil, depsErr
 		} else {
 			deps = thisDeps
+			factoryCtx = withDependencyInfo(ctx, info)
+		}
+	}
+	if o.scoped {
+		factoryCtx = withScope(factoryCtx, o.scope(name))
+	}
+	start := time.Now()
+	result, attempts, err := n.call(factoryCtx, name, params, deps)
+	var usedFallback bool
+	if err != nil && ctx.Err() == nil && n.fallback != nil {
+		fallbackResult, fallbackErr := callFactory(factoryCtx, name, n.fallback, params, deps)
+		if fallbackErr == nil {
+			o.addFallback(name)
+			result, err, usedFallback = fallbackResult, nil, true
+		} else {
+			err = errors.Join(err, fmt.Errorf("fallback failed: %w", fallbackErr))
+		}
+	}
+	if hook := hookFrom(ctx); hook != nil {
+		hook(FactoryCall{
+			Name:     name,
+			Params:   params,
+			Start:    start,
+			Duration: time.Since(start),
+			Value:    result,
+			Err:      err,
+			Attempts: attempts,
+			Fallback: usedFallback,
+		})
+	}
+	if ctx.Err() != nil {
+		return nil, contextError(ctx, path)
+	}
+	if err != nil {
+		kind := FactoryFailed
+		if _, ok := err.(*TimeoutError); ok {
+			kind = FactoryTimedOut
 		}
+		resolutionErr := newResolutionError(path, kind, err)
+		resolutionErr.Attempts = attempts
+		return nil, resolutionErr
 	}
-	result, err := factory(ctx, params, deps)
+	return result, nil
+}
+
+// getSwitch fetches the target chosen by a switch node.
+// The switch is the last name in path.
+func (o *onceController) getSwitch(ctx context.Context, cancelFunc context.CancelCauseFunc, params interface{}, path []string, n *node) (interface{}, error) {
+	name := path[len(path)-1]
+	target, err := chooseCase(ctx, name, params, n.cases)
 	if err != nil {
-		return abort(cancelFunc, err)
+		return nil, newResolutionError(path, FactoryFailed, err)
+	}
+	if o.isRequired(name) {
+		o.require(target)
 	}
-	return result, ctx.Err()
+	return o.getOnce(ctx, cancelFunc, params, path, target)
 }
 
-// getDependencies resolves all dependencies for a factory.
-// Dependencies are resolved asynchronously.
-func (o *onceController) getDependencies(ctx context.Context, cancelFunc func(), params interface{}, depConditions conditionMap, parent, name string) (Dependencies, error) {
+// getDependencies resolves all dependencies for a factory, describing how
+// each was fulfilled. Dependencies are resolved asynchronously.
+// path is the chain of names that led to the factory, and is empty when
+// resolving the roots of a resolution.
+func (o *onceController) getDependencies(ctx context.Context, cancelFunc context.CancelCauseFunc, params interface{}, edges edgeMap, path []string) (Dependencies, *dependencyInfo, error) {
 	deps := make(Dependencies)
-	if len(depConditions) == 0 {
-		return deps, nil
+	info := newDependencyInfo()
+	if len(edges) == 0 {
+		return deps, info, nil
 	}
+	required := len(path) == 0 || o.isRequired(path[len(path)-1])
 	var depsErr error
 	m := new(sync.Mutex)
+	setErr := func(err error) {
+		m.Lock()
+		if depsErr == nil {
+			depsErr = err
+		}
+		m.Unlock()
+	}
 	wg := new(sync.WaitGroup)
-	wg.Add(depConditions.Size())
-	for depName, conditions := range depConditions {
-		go func(depName string, conditions []Condition) {
+	wg.Add(edges.Size())
+	for depName, e := range edges {
+		go func(depName string, e *edge) {
+			defer wg.Done()
+			depPath := append(path[:len(path):len(path)], depName)
+			key := e.key(depName)
 			select {
 			case <-ctx.Done():
-				depsErr = ctx.Err()
+				setErr(contextError(ctx, depPath))
+				return
 			default:
-				var err error
-				var result interface{}
-				if !checkConditions(params, conditions) {
-					result = nil
-				} else {
-					result, err = o.getOnce(ctx, cancelFunc, params, name, depName)
-				}
-				if err != nil {
-					_, depsErr = abort(cancelFunc, err)
-				} else {
+			}
+			unmet, err := callConditions(ctx, depName, params, e.conditions)
+			if err == nil && unmet != nil {
+				m.Lock()
+				deps[key] = nil
+				info.skip(key, skipReason(unmet))
+				m.Unlock()
+				return
+			}
+			if err != nil {
+				err = newResolutionError(depPath, FactoryFailed, err)
+			} else {
+				var unmetGuard *guard
+				unmetGuard, err = o.checkGuards(ctx, cancelFunc, params, path, depPath, e, required && !e.optional)
+				if err == nil && unmetGuard != nil {
 					m.Lock()
-					deps[depName] = result
+					deps[key] = nil
+					info.skip(key, skipReason(unmetGuard))
 					m.Unlock()
+					return
+				}
+			}
+			var result interface{}
+			if err == nil {
+				if required && !e.optional {
+					o.require(depName)
+				}
+				result, err = o.getOnce(ctx, cancelFunc, params, path, depName)
+			}
+			switch {
+			case err == nil:
+				m.Lock()
+				deps[key] = result
+				info.resolve(key)
+				m.Unlock()
+			case e.optional && ctx.Err() == nil:
+				m.Lock()
+				info.fail(key, err)
+				m.Unlock()
+			case e.conditional() && o.nilOnTimeout(depName, err):
+				m.Lock()
+				deps[key] = nil
+				info.fail(key, err)
+				m.Unlock()
+			default:
+				setErr(err)
+				if required {
+					// Fail fast: the resolution cannot succeed.
+					cancelFunc(err)
 				}
 			}
-			wg.Done()
-		}(depName, conditions)
+		}(depName, e)
 	}
 	wg.Wait()
-	return deps, depsErr
+	return deps, info, depsErr
+}
+
+// checkGuards resolves the prerequisites of each guard on an edge, one at a
+// time, and checks its DataCondition against them. It returns the first guard
+// that is not met, or nil if they all are.
+// path leads to the parent of the edge and depPath to its dependency.
+// Prerequisites are marked as required when require is true.
+func (o *onceController) checkGuards(ctx context.Context, cancelFunc context.CancelCauseFunc, params interface{}, path, depPath []string, e *edge, require bool) (*guard, error) {
+	for _, g := range e.guards {
+		prerequisites := make(Dependencies, len(g.prerequisites))
+		for i, prerequisite := range g.prerequisites {
+			if require {
+				o.require(prerequisite)
+			}
+			value, err := o.getOnce(ctx, cancelFunc, params, path, prerequisite)
+			if err != nil {
+				return nil, err
+			}
+			prerequisites[g.key(i)] = value
+		}
+		ok, err := callDataCondition(ctx, depPath[len(depPath)-1], params, g.condition, prerequisites)
+		if err != nil {
+			return nil, newResolutionError(depPath, FactoryFailed, err)
+		}
+		if !ok {
+			return g, nil
+		}
+	}
+	return nil, nil
 }
 
-// abort is a helper that calls a cancel function and returns a nil value and the error.
-func abort(cancelFunc func(), err error) (interface{}, error) {
-	cancelFunc()
-	return nil, err
+// nilOnTimeout returns true if err means the named node exceeded its timeout
+// and the node should then be delivered as nil to dependents.
+func (o *onceController) nilOnTimeout(name string, err error) bool {
+	name = o.q.canonical(name)
+	resolutionErr, ok := err.(*ResolutionError)
+	if !ok || resolutionErr.Kind != FactoryTimedOut || resolutionErr.Node != name {
+		return false
+	}
+	n, _, ok := o.q.node(name)
+	return ok && n.nilOnTimeout
+}
+
+// callFactory calls a Factory, converting a panic into a *PanicError.
+func callFactory(ctx context.Context, name string, factory Factory, params interface{}, deps Dependencies) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, newPanicError(name, r)
+		}
+	}()
+	return factory(ctx, params, deps)
+}
+
+// callConditions checks the conditions for a dependency, returning the first
+// one that is not met, and converting a panic into a *PanicError.
+func callConditions(ctx context.Context, name string, params interface{}, conditions []Predicate) (unmet Predicate, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			unmet, err = nil, newPanicError(name, r)
+		}
+	}()
+	return checkConditions(ctx, params, conditions), nil
+}
+
+// callDataCondition checks a DataCondition for a dependency, converting a panic into a *PanicError.
+func callDataCondition(ctx context.Context, name string, params interface{}, condition DataCondition, prerequisites Dependencies) (ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ok, err = false, newPanicError(name, r)
+		}
+	}()
+	return condition(ctx, params, prerequisites), nil
 }
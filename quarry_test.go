@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync/atomic"
 	"testing"
 
@@ -72,6 +73,68 @@ func TestQuarryImpl_AddDependency_cycleResultsInError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestQuarryImpl_AddDependency_cycleErrorHasPath(t *testing.T) {
+	q := quarry.New()
+	q.AddDependency("a", "b")
+	q.AddDependency("b", "c")
+	q.AddDependency("c", "d")
+
+	err := q.AddDependency("c", "a")
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.Equal(t, []string{"c", "a", "b", "c"}, cycleErr.Path)
+	assert.Contains(t, err.Error(), "c -> a -> b -> c")
+}
+
+func TestQuarryImpl_AddDependency_cycleIsRolledBack(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddFactory("b", factoryOk())
+	q.AddDependency("a", "b")
+	q.AddDependency("b", "a")
+
+	err := q.AddDependency("b", "a", func(interface{}) bool { return true })
+	_, getErr := q.Get(context.Background(), nil, "b")
+
+	var cycleErr *quarry.CycleError
+	assert.True(t, errors.As(err, &cycleErr))
+	assert.NoError(t, getErr)
+}
+
+func TestQuarryImpl_AddDependency_detectsCyclesLikeFullSearch(t *testing.T) {
+	const numNodes = 50
+	r := rand.New(rand.NewSource(1))
+	q := quarry.New()
+	adjacency := make(map[int][]int)
+	var reaches func(from, to int, visited map[int]bool) bool
+	reaches = func(from, to int, visited map[int]bool) bool {
+		if from == to {
+			return true
+		}
+		visited[from] = true
+		for _, next := range adjacency[from] {
+			if !visited[next] && reaches(next, to, visited) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < 1000; i++ {
+		parent, dependsOn := r.Intn(numNodes), r.Intn(numNodes)
+		createsCycle := reaches(dependsOn, parent, make(map[int]bool))
+
+		err := q.AddDependency(fmt.Sprint(parent), fmt.Sprint(dependsOn))
+
+		var cycleErr *quarry.CycleError
+		assert.Equal(t, createsCycle, errors.As(err, &cycleErr), "%d -> %d", parent, dependsOn)
+		if err == nil {
+			adjacency[parent] = append(adjacency[parent], dependsOn)
+		}
+	}
+}
+
 func TestQuarryImpl_Get(t *testing.T) {
 	_, q := simpleGraph(nil)
 
@@ -166,6 +229,105 @@ func TestQuarryImpl_Get_contextDoneHalfwayResultsInError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestQuarryImpl_Get_factoryErrorIsResolutionError(t *testing.T) {
+	someErr := errors.New("some-error")
+	q := quarry.New()
+	q.AddFactory("root", factoryOk())
+	q.AddFactory("a", factoryOk())
+	q.AddFactory("b", factoryErr(someErr))
+	q.AddDependency("root", "a")
+	q.AddDependency("a", "b")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, "b", resolutionErr.Node)
+	assert.Equal(t, []string{"root", "a", "b"}, resolutionErr.Path)
+	assert.Equal(t, quarry.FactoryFailed, resolutionErr.Kind)
+	assert.True(t, errors.Is(err, someErr))
+}
+
+func TestQuarryImpl_Get_missingFactoryIsResolutionError(t *testing.T) {
+	q := quarry.New()
+	q.AddFactory("root", factoryOk())
+	q.AddDependency("root", "missing")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, []string{"root", "missing"}, resolutionErr.Path)
+	assert.Equal(t, quarry.FactoryMissing, resolutionErr.Kind)
+}
+
+func TestQuarryImpl_Get_contextDoneIsResolutionError(t *testing.T) {
+	q := quarry.New()
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	q.AddFactory("root", factoryOk())
+	q.AddFactory("a", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		cancelFunc()
+		return nil, nil
+	})
+	q.AddDependency("root", "a")
+
+	_, err := q.Get(ctx, nil, "root")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, []string{"root", "a"}, resolutionErr.Path)
+	assert.Equal(t, quarry.ContextDone, resolutionErr.Kind)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestQuarryImpl_Get_failureIsReportedOverCancellation(t *testing.T) {
+	someErr := errors.New("some-error")
+	q := quarry.New()
+	q.AddFactory("root", factoryOk())
+	q.AddFactory("slow", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		<-ctx.Done()
+		return nil, nil
+	})
+	q.AddFactory("failing", factoryErr(someErr))
+	q.AddDependency("root", "slow")
+	q.AddDependency("root", "failing")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.True(t, errors.Is(err, someErr))
+}
+
+func TestQuarryImpl_Get_factoryPanicIsPanicError(t *testing.T) {
+	q := quarry.New()
+	q.AddFactory("root", factoryOk())
+	q.AddFactory("a", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return deps["missing"].(string), nil
+	})
+	q.AddDependency("root", "a")
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var panicErr *quarry.PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "a", panicErr.Node)
+	assert.NotEmpty(t, panicErr.Stack)
+}
+
+func TestQuarryImpl_Get_conditionPanicIsPanicError(t *testing.T) {
+	q := quarry.New()
+	q.AddFactory("root", factoryOk())
+	q.AddFactory("a", factoryOk())
+	q.AddDependency("root", "a", func(params interface{}) bool {
+		return params.(bool)
+	})
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var panicErr *quarry.PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "a", panicErr.Node)
+}
+
 func TestQuarryImpl_Get_passesParams(t *testing.T) {
 	q := quarry.New()
 	expectedParams := new(int64)
@@ -287,6 +449,48 @@ func BenchmarkQuarryImpl_Get(b *testing.B) {
 	}
 }
 
+func BenchmarkQuarryImpl_AddDependency_10k(b *testing.B) {
+	benchmarkAddDependency(b, 10000)
+}
+
+func BenchmarkQuarryImpl_AddDependency_100k(b *testing.B) {
+	benchmarkAddDependency(b, 100000)
+}
+
+// benchmarkAddDependency registers numEdges edges of a random acyclic graph,
+// in random order.
+func benchmarkAddDependency(b *testing.B, numEdges int) {
+	const edgesPerNode = 4
+	numNodes := numEdges / edgesPerNode
+	r := rand.New(rand.NewSource(1))
+	names := make([]string, numNodes)
+	for i := range names {
+		names[i] = fmt.Sprintf("node-%d", i)
+	}
+	type edge struct{ parent, dependsOn string }
+	seen := make(map[edge]bool)
+	edges := make([]edge, 0, numEdges)
+	for len(edges) < numEdges {
+		parent := 1 + r.Intn(numNodes-1)
+		e := edge{names[parent], names[r.Intn(parent)]}
+		if !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+	r.Shuffle(len(edges), func(i, j int) {
+		edges[i], edges[j] = edges[j], edges[i]
+	})
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		q := quarry.New()
+		for _, e := range edges {
+			q.MustAddDependency(e.parent, e.dependsOn)
+		}
+	}
+}
+
 func BenchmarkQuarryImpl_Get_longerKeys(b *testing.B) {
 	factory := factoryOk()
 	q := quarry.New()
@@ -333,6 +537,13 @@ func factoryErr(err error) quarry.Factory {
 	}
 }
 
+// factoryDeps returns the Dependencies it was given.
+func factoryDeps() quarry.Factory {
+	return func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return deps, nil
+	}
+}
+
 func factoryOk() quarry.Factory {
 	return factoryValue(0)
 }
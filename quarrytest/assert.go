@@ -0,0 +1,52 @@
+package quarrytest
+
+import "testing"
+
+// AssertResolved reports an error unless the Factory of every named node was
+// called and succeeded in a resolution recorded by rec.
+func AssertResolved(t testing.TB, rec *Recorder, names ...string) bool {
+	t.Helper()
+	ok := true
+	for _, name := range names {
+		var succeeded bool
+		for _, call := range rec.CallsOf(name) {
+			if call.Err == nil {
+				succeeded = true
+				break
+			}
+		}
+		if !succeeded {
+			t.Errorf("quarrytest: %s was not resolved", name)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertNotResolved reports an error if the Factory of any named node was
+// called in a resolution recorded by rec.
+func AssertNotResolved(t testing.TB, rec *Recorder, names ...string) bool {
+	t.Helper()
+	ok := true
+	for _, name := range names {
+		if calls := rec.CallsOf(name); len(calls) != 0 {
+			t.Errorf("quarrytest: %s was resolved %d times, want 0", name, len(calls))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// AssertCalledOnce reports an error unless the Factory of every named node
+// was called exactly once across the resolutions recorded by rec.
+func AssertCalledOnce(t testing.TB, rec *Recorder, names ...string) bool {
+	t.Helper()
+	ok := true
+	for _, name := range names {
+		if calls := rec.CallsOf(name); len(calls) != 1 {
+			t.Errorf("quarrytest: %s was called %d times, want 1", name, len(calls))
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,31 @@
+// Package quarrytest provides helpers for testing code that uses a Quarry.
+package quarrytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/explodes/quarry"
+)
+
+// Override replaces the Factory registered under name for the rest of the
+// test, keeping its dependencies, and restores it when the test finishes.
+// See quarry.Quarry.Override.
+func Override(t testing.TB, q quarry.Quarry, name string, factory quarry.Factory) {
+	t.Helper()
+	restore, err := override(q, name, factory)
+	if err != nil {
+		t.Fatalf("quarrytest: %v", err)
+	}
+	t.Cleanup(restore)
+}
+
+// override calls Override, converting a panic into an error.
+func override(q quarry.Quarry, name string, factory quarry.Factory) (restore func(), err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			restore, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	return q.Override(name, factory), nil
+}
@@ -0,0 +1,28 @@
+package quarrytest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/explodes/quarry/quarrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverride_restoresOnCleanup(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("userStorage", quarry.Provider("sql"))
+
+	t.Run("overridden", func(t *testing.T) {
+		quarrytest.Override(t, q, "userStorage", quarry.Provider("fake"))
+
+		value, err := q.Get(context.Background(), nil, "userStorage")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "fake", value)
+	})
+	value, err := q.Get(context.Background(), nil, "userStorage")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "sql", value)
+}
@@ -0,0 +1,150 @@
+package quarrytest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/explodes/quarry"
+)
+
+// Resolution is what a Recorder recorded for a single Get, Resolve or GetAll.
+type Resolution struct {
+	// Names are the names of the requested nodes.
+	Names []string
+	// Params are the parameters of the resolution.
+	Params interface{}
+	// Calls are the factories that were called, in the order they returned.
+	Calls []quarry.FactoryCall
+	// Duration is how long the resolution took.
+	Duration time.Duration
+	// Err is the error of the resolution, if it failed.
+	Err error
+}
+
+// CallsOf returns the calls of the named node's Factory.
+func (r *Resolution) CallsOf(name string) []quarry.FactoryCall {
+	var calls []quarry.FactoryCall
+	for _, call := range r.Calls {
+		if call.Name == name {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Recorder is a Quarry that records, for every resolution, which factories
+// were called, with what parameters, in what order, how long each took and
+// what each returned.
+type Recorder struct {
+	quarry.Quarry
+
+	m           sync.Mutex
+	resolutions []*Resolution
+}
+
+// NewRecorder creates a Recorder that resolves nodes using q.
+func NewRecorder(q quarry.Quarry) *Recorder {
+	return &Recorder{Quarry: q}
+}
+
+func (r *Recorder) Get(ctx context.Context, params interface{}, name string) (interface{}, error) {
+	result, err := r.Resolve(ctx, params, name)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+func (r *Recorder) MustGet(ctx context.Context, params interface{}, name string) interface{} {
+	value, err := r.Get(ctx, params, name)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func (r *Recorder) Resolve(ctx context.Context, params interface{}, name string) (*quarry.Result, error) {
+	var result *quarry.Result
+	err := r.record(ctx, params, []string{name}, func(ctx context.Context) (err error) {
+		result, err = r.Quarry.Resolve(ctx, params, name)
+		return err
+	})
+	return result, err
+}
+
+func (r *Recorder) GetAll(ctx context.Context, params interface{}, names ...string) (quarry.Dependencies, error) {
+	var results quarry.Dependencies
+	err := r.record(ctx, params, names, func(ctx context.Context) (err error) {
+		results, err = r.Quarry.GetAll(ctx, params, names...)
+		return err
+	})
+	return results, err
+}
+
+func (r *Recorder) MustGetAll(ctx context.Context, params interface{}, names ...string) quarry.Dependencies {
+	results, err := r.GetAll(ctx, params, names...)
+	if err != nil {
+		panic(err)
+	}
+	return results
+}
+
+// record runs a resolution, recording the factories it calls.
+func (r *Recorder) record(ctx context.Context, params interface{}, names []string, resolve func(ctx context.Context) error) error {
+	resolution := &Resolution{
+		Names:  append([]string(nil), names...),
+		Params: params,
+	}
+	var m sync.Mutex
+	ctx = quarry.WithHook(ctx, func(call quarry.FactoryCall) {
+		m.Lock()
+		resolution.Calls = append(resolution.Calls, call)
+		m.Unlock()
+	})
+	start := time.Now()
+	err := resolve(ctx)
+	m.Lock()
+	resolution.Duration = time.Since(start)
+	resolution.Err = err
+	m.Unlock()
+
+	r.m.Lock()
+	r.resolutions = append(r.resolutions, resolution)
+	r.m.Unlock()
+	return err
+}
+
+// Resolutions returns every resolution recorded since the Recorder was
+// created or last reset, in the order they finished.
+func (r *Recorder) Resolutions() []*Resolution {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]*Resolution(nil), r.resolutions...)
+}
+
+// Last returns the last resolution recorded, or nil if there is none.
+func (r *Recorder) Last() *Resolution {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if len(r.resolutions) == 0 {
+		return nil
+	}
+	return r.resolutions[len(r.resolutions)-1]
+}
+
+// CallsOf returns the calls of the named node's Factory in every resolution recorded.
+func (r *Recorder) CallsOf(name string) []quarry.FactoryCall {
+	var calls []quarry.FactoryCall
+	for _, resolution := range r.Resolutions() {
+		calls = append(calls, resolution.CallsOf(name)...)
+	}
+	return calls
+}
+
+// Reset forgets every resolution recorded.
+func (r *Recorder) Reset() {
+	r.m.Lock()
+	r.resolutions = nil
+	r.m.Unlock()
+}
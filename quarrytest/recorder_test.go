@@ -0,0 +1,111 @@
+package quarrytest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/explodes/quarry/quarrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeT records the errors reported by assertions.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func inboxGraph() quarry.Quarry {
+	q := quarry.New()
+	q.MustAddFactory("inbox", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return deps, nil
+	})
+	q.MustAddFactory("notifications", quarry.Provider("notifications"))
+	q.MustAddFactory("unreadNotifications", quarry.Provider("unread"))
+	q.MustAddDependency("inbox", "notifications")
+	q.MustAddDependency("inbox", "unreadNotifications", func(params interface{}) bool { return params == true })
+	return q
+}
+
+func TestRecorder_Get(t *testing.T) {
+	rec := quarrytest.NewRecorder(inboxGraph())
+
+	_, err := rec.Get(context.Background(), false, "inbox")
+
+	assert.NoError(t, err)
+	resolution := rec.Last()
+	assert.Equal(t, []string{"inbox"}, resolution.Names)
+	assert.Equal(t, false, resolution.Params)
+	assert.Len(t, resolution.Calls, 2)
+	assert.Equal(t, "notifications", resolution.Calls[0].Name)
+	assert.Equal(t, "inbox", resolution.Calls[1].Name)
+	assert.Equal(t, "notifications", resolution.Calls[0].Value)
+	quarrytest.AssertResolved(t, rec, "inbox", "notifications")
+	quarrytest.AssertNotResolved(t, rec, "unreadNotifications")
+	quarrytest.AssertCalledOnce(t, rec, "inbox", "notifications")
+}
+
+func TestRecorder_recordsEachResolution(t *testing.T) {
+	rec := quarrytest.NewRecorder(inboxGraph())
+
+	rec.MustGet(context.Background(), false, "inbox")
+	rec.MustGetAll(context.Background(), true, "inbox", "notifications")
+
+	resolutions := rec.Resolutions()
+	assert.Len(t, resolutions, 2)
+	assert.Len(t, resolutions[0].Calls, 2)
+	assert.Len(t, resolutions[1].Calls, 3)
+	assert.Equal(t, []string{"inbox", "notifications"}, resolutions[1].Names)
+	assert.Len(t, rec.CallsOf("notifications"), 2)
+}
+
+func TestRecorder_recordsErrors(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("failing", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return nil, fmt.Errorf("some-error")
+	})
+	rec := quarrytest.NewRecorder(q)
+
+	_, err := rec.Get(context.Background(), nil, "failing")
+
+	assert.Error(t, err)
+	assert.Equal(t, err, rec.Last().Err)
+	assert.EqualError(t, rec.Last().Calls[0].Err, "some-error")
+}
+
+func TestRecorder_Reset(t *testing.T) {
+	rec := quarrytest.NewRecorder(inboxGraph())
+	rec.MustGet(context.Background(), false, "inbox")
+
+	rec.Reset()
+
+	assert.Nil(t, rec.Last())
+	assert.Empty(t, rec.Resolutions())
+}
+
+func TestAssertions_reportFailures(t *testing.T) {
+	rec := quarrytest.NewRecorder(inboxGraph())
+	rec.MustGet(context.Background(), false, "inbox")
+	rec.MustGet(context.Background(), false, "inbox")
+	fake := &fakeT{}
+
+	resolved := quarrytest.AssertResolved(fake, rec, "unreadNotifications")
+	notResolved := quarrytest.AssertNotResolved(fake, rec, "inbox")
+	calledOnce := quarrytest.AssertCalledOnce(fake, rec, "inbox")
+
+	assert.False(t, resolved)
+	assert.False(t, notResolved)
+	assert.False(t, calledOnce)
+	assert.Equal(t, []string{
+		"quarrytest: unreadNotifications was not resolved",
+		"quarrytest: inbox was resolved 2 times, want 0",
+		"quarrytest: inbox was called 2 times, want 1",
+	}, fake.errors)
+}
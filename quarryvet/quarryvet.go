@@ -0,0 +1,195 @@
+// Package quarryvet defines an Analyzer that checks how the nodes of a Quarry
+// are wired together, finding at compile time the mistakes that would
+// otherwise only show up when the graph is resolved:
+//
+//   - nodes registered more than once in the same shared Quarry, such as one
+//     returned by a package's Default function, even from different packages;
+//   - dependencies on nodes that are never registered anywhere in a program;
+//   - Factories that read deps["x"] for an x that is never declared as one of
+//     their dependencies.
+//
+// Only nodes and dependencies named by constants can be checked. A program
+// that registers nodes under other names, or installs a Module, is not checked
+// for dependencies on missing nodes. As any package of a program may declare
+// the dependencies of a Factory, both missing nodes and the dependencies read
+// by Factories are checked in the main package.
+//
+// The Analyzer can be run with go vet, see the quarryvet command.
+package quarryvet
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer checks how the nodes of a Quarry are wired together.
+var Analyzer = &analysis.Analyzer{
+	Name:      "quarryvet",
+	Doc:       "check how the nodes of a Quarry are wired together",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(wiring)},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if pass.Pkg.Path() == quarryPath {
+		// The quarry package registers nodes on behalf of its callers.
+		return nil, nil
+	}
+	w := &wiring{}
+	var imported []*wiring
+	for _, pkg := range pass.Pkg.Imports() {
+		fact := &wiring{}
+		if pass.ImportPackageFact(pkg, fact) {
+			imported = append(imported, fact)
+			w.add(fact)
+		}
+	}
+	s := newScanner(pass, w)
+	s.scan()
+	w.add(s.wiring)
+	if len(w.Registrations) > 0 || len(w.Dependencies) > 0 || len(w.Reads) > 0 || w.DynamicNodes || w.DynamicEdges {
+		pass.ExportPackageFact(w)
+	}
+
+	checkDuplicates(pass, s, w, imported)
+	if pass.Pkg.Name() == "main" {
+		checkMissing(pass, s, w)
+		checkReads(pass, s, w)
+	}
+	return nil, nil
+}
+
+// checkDuplicates reports nodes registered more than once in a shared Quarry.
+// Duplicates are reported where they are registered, or, if they are all
+// registered by imported packages, in the first package that imports them all.
+func checkDuplicates(pass *analysis.Pass, s *scanner, w *wiring, imported []*wiring) {
+	type node struct{ graph, name string }
+	var nodes []node
+	registrations := make(map[node][]registration)
+	for _, r := range w.Registrations {
+		if r.Graph == "" {
+			continue
+		}
+		n := node{r.Graph, r.Name}
+		if _, ok := registrations[n]; !ok {
+			nodes = append(nodes, n)
+		}
+		registrations[n] = append(registrations[n], r)
+	}
+	for _, n := range nodes {
+		duplicates := registrations[n]
+		if len(duplicates) < 2 {
+			continue
+		}
+		var local bool
+		for i, r := range duplicates {
+			if pos := s.local(r.Pos); pos.IsValid() {
+				local = true
+				if i > 0 {
+					pass.Reportf(pos, "%s is already registered at %s", r.Name, duplicates[0].Pos)
+				}
+			}
+		}
+		if !local && !importedTogether(imported, duplicates) {
+			positions := make([]string, len(duplicates))
+			for i, r := range duplicates {
+				positions[i] = r.Pos
+			}
+			pass.Reportf(packagePos(pass), "%s is registered more than once, at %s", n.name, strings.Join(positions, " and "))
+		}
+	}
+}
+
+// importedTogether returns true if a single imported package already holds
+// every registration, in which case the duplicates were reported there.
+func importedTogether(imported []*wiring, registrations []registration) bool {
+	for _, w := range imported {
+		all := true
+		for _, r := range registrations {
+			all = all && w.contains(r)
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMissing reports dependencies on nodes that are never registered in a program.
+func checkMissing(pass *analysis.Pass, s *scanner, w *wiring) {
+	if w.DynamicNodes {
+		return
+	}
+	registered := make(map[string]bool)
+	for _, r := range w.Registrations {
+		registered[r.Name] = true
+	}
+	for _, d := range w.Dependencies {
+		if d.Dynamic || d.Optional || registered[d.DependsOn] {
+			continue
+		}
+		if pos := s.local(d.Pos); pos.IsValid() {
+			pass.Reportf(pos, "%s depends on %s, which is never registered", d.Parent, d.DependsOn)
+		} else {
+			pass.Reportf(packagePos(pass), "%s depends on %s at %s, which is never registered", d.Parent, d.DependsOn, d.Pos)
+		}
+	}
+}
+
+// checkReads reports Factories that read dependencies that are never declared.
+func checkReads(pass *analysis.Pass, s *scanner, w *wiring) {
+	if w.DynamicEdges {
+		return
+	}
+	for _, r := range w.Reads {
+		keys, ok := dependencyKeys(w, r.Parent)
+		if !ok || keys[r.Key] {
+			continue
+		}
+		if pos := s.local(r.Pos); pos.IsValid() {
+			pass.Reportf(pos, "%s reads %s[%q], but does not depend on it%s", r.Parent, r.Variable, r.Key, declared(keys))
+		} else {
+			pass.Reportf(packagePos(pass), "%s reads %s[%q] at %s, but does not depend on it%s", r.Parent, r.Variable, r.Key, r.Pos, declared(keys))
+		}
+	}
+}
+
+// dependencyKeys returns the keys the dependencies of the named node are
+// delivered under, or false if they are not all known.
+func dependencyKeys(w *wiring, name string) (map[string]bool, bool) {
+	keys := make(map[string]bool)
+	for _, d := range w.Dependencies {
+		if d.Parent != name {
+			continue
+		}
+		if d.Dynamic {
+			return nil, false
+		}
+		if d.Key != "" {
+			keys[d.Key] = true
+		}
+	}
+	return keys, true
+}
+
+// declared describes the keys a Factory does depend on.
+func declared(keys map[string]bool) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return " (dependencies: " + strings.Join(sorted, ", ") + ")"
+}
+
+// packagePos returns the position of the package clause, where problems of
+// the package as a whole are reported.
+func packagePos(pass *analysis.Pass) token.Pos {
+	return pass.Files[0].Package
+}
@@ -0,0 +1,12 @@
+package quarryvet_test
+
+import (
+	"testing"
+
+	"github.com/explodes/quarry/quarryvet"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), quarryvet.Analyzer, "users", "app", "dynamic", "repository", "server")
+}
@@ -0,0 +1,19 @@
+package main // want package:"wiring\\(13 registrations, 11 dependencies\\)" `cache is registered more than once, at .*storage.go:\d+:\d+ and .*users.go:\d+:\d+` `userStorage depends on secret at .*storage.go:\d+:\d+, which is never registered` `user depends on sessionStore at .*users.go:\d+:\d+, which is never registered` `typedUser reads deps\["user"\] at .*users.go:\d+:\d+, but does not depend on it` `userService reads deps\["userStorage"\] at .*users.go:\d+:\d+, but does not depend on it` `user reads deps\["sessionStore"\] at .*users.go:\d+:\d+, but does not depend on it \(dependencies: audit, sessions, userStorage\)`
+
+import (
+	"github.com/explodes/quarry"
+	"registry"
+	_ "storage"
+	_ "users"
+)
+
+func main() {
+	q := registry.Default()
+	q.MustAddFactory("response", quarry.Provider(nil))
+	q.MustAddDependency("response", "user")
+	q.MustAddDependency("response", "inbox")                                                     // want `response depends on inbox, which is never registered`
+	q.MustAddSwitch("storage", quarry.Case(nil, "userStorage"), quarry.Default("memoryStorage")) // want `storage depends on memoryStorage, which is never registered`
+	q.MustAddAlias("users", "user")
+	q.MustAddFactory("config", quarry.Provider(nil)) // want `config is already registered at .*registry.go:\d+:\d+`
+	q.MustAddEdge("response", "stats", quarry.Optional())
+}
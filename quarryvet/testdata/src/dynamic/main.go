@@ -0,0 +1,14 @@
+package main // want package:"wiring\\(1 registrations, 1 dependencies\\)"
+
+import (
+	"github.com/explodes/quarry"
+	"registry"
+)
+
+func main() {
+	q := registry.Default()
+	for _, name := range []string{"a", "b"} {
+		q.MustAddFactory(name, quarry.Provider(nil))
+	}
+	q.MustAddDependency("response", "a")
+}
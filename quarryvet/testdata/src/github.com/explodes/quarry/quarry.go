@@ -0,0 +1,76 @@
+// Package quarry is a stub of the parts of the quarry package the analyzer
+// recognizes.
+package quarry
+
+import "context"
+
+type Dependencies map[string]interface{}
+
+type Factory func(ctx context.Context, params interface{}, deps Dependencies) (interface{}, error)
+
+type TypedFactory[T any] func(ctx context.Context, params interface{}, deps Dependencies) (T, error)
+
+type FactoryOption func()
+
+type EdgeOption func()
+
+type Condition func(params interface{}) bool
+
+type Predicate interface{}
+
+type DataCondition func(ctx context.Context, params interface{}, prerequisites Dependencies) bool
+
+type SwitchCase struct{}
+
+type Module struct{}
+
+type Key[T any] struct{ name string }
+
+type Quarry interface {
+	AddFactory(name string, factory Factory, options ...FactoryOption) error
+	MustAddFactory(name string, factory Factory, options ...FactoryOption)
+	AddConstructor(name string, fn interface{}, dependsOn ...string) error
+	MustAddConstructor(name string, fn interface{}, dependsOn ...string)
+	AddDependency(parent, dependsOn string, conditions ...Condition) error
+	MustAddDependency(parent, dependsOn string, conditions ...Condition)
+	AddEdge(parent, dependsOn string, options ...EdgeOption) error
+	MustAddEdge(parent, dependsOn string, options ...EdgeOption)
+	AddFallback(name string, fallback Factory) error
+	MustAddFallback(name string, fallback Factory)
+	AddSwitch(name string, cases ...SwitchCase) error
+	MustAddSwitch(name string, cases ...SwitchCase)
+	AddAlias(alias, target string) error
+	MustAddAlias(alias, target string)
+	Install(m *Module) error
+	MustInstall(m *Module)
+	Child() Quarry
+}
+
+func New() Quarry { return nil }
+
+func Singleton(factory func(ctx context.Context, deps Dependencies) (interface{}, error)) Factory {
+	return nil
+}
+
+func Provider(value interface{}) Factory { return nil }
+
+func Optional() EdgeOption { return nil }
+
+func As(key string) EdgeOption { return nil }
+
+func WhenAll(predicates ...Predicate) EdgeOption { return nil }
+
+func WhenResolved(condition DataCondition, prerequisites ...string) EdgeOption { return nil }
+
+func Case(predicate Predicate, target string) SwitchCase { return SwitchCase{} }
+
+func Default(target string) SwitchCase { return SwitchCase{} }
+
+func NewModule(prefix string) *Module { return nil }
+
+func NewKey[T any](name string) Key[T] { return Key[T]{name: name} }
+
+func MustAddTypedFactory[T any](q Quarry, key Key[T], factory TypedFactory[T], options ...FactoryOption) {
+	// Names registered by the quarry package itself are not checked.
+	q.MustAddFactory(key.name, nil, options...)
+}
@@ -0,0 +1,13 @@
+package registry
+
+import "github.com/explodes/quarry"
+
+var graph = quarry.New()
+
+func init() {
+	graph.MustAddFactory("config", quarry.Provider(nil))
+}
+
+func Default() quarry.Quarry {
+	return graph
+}
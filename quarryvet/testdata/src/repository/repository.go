@@ -0,0 +1,17 @@
+package repository // want package:"wiring\\(2 registrations, 0 dependencies\\)"
+
+import (
+	"context"
+
+	"github.com/explodes/quarry"
+)
+
+// Register leaves it to the program to provide the databases.
+func Register(q quarry.Quarry) {
+	q.MustAddFactory("repo", newRepo)
+	q.MustAddFactory("replicaRepo", newRepo)
+}
+
+func newRepo(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+	return deps["db"], nil
+}
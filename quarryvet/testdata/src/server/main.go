@@ -0,0 +1,20 @@
+package main // want package:"wiring\\(5 registrations, 1 dependencies\\)" `replicaRepo reads deps\["db"\] at .*repository.go:\d+:\d+, but does not depend on it`
+
+import (
+	"context"
+
+	"github.com/explodes/quarry"
+	"repository"
+)
+
+func main() {
+	q := quarry.New()
+	repository.Register(q)
+	q.MustAddFactory("primaryDB", quarry.Provider(nil))
+	q.MustAddEdge("repo", "primaryDB", quarry.As("db"))
+	(q.MustAddFactory)("cache", quarry.Provider(nil))
+	quarry.Quarry.MustAddFactory(q, "skipped", quarry.Provider(nil))
+	q.MustAddFactory("handler", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return deps["repo"], nil // want `handler reads deps\["repo"\], but does not depend on it`
+	})
+}
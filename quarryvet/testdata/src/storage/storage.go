@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/explodes/quarry"
+	"registry"
+)
+
+func init() {
+	q := registry.Default()
+
+	q.MustAddFactory("userStorage", quarry.Singleton(buildUserStorage))
+	q.MustAddDependency("userStorage", "secret")
+
+	q.MustAddFactory("cache", quarry.Provider(nil))
+}
+
+func buildUserStorage(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+	return deps["secret"], nil
+}
@@ -0,0 +1,50 @@
+package users // want package:"wiring\\(7 registrations, 4 dependencies\\)"
+
+import (
+	"context"
+
+	"github.com/explodes/quarry"
+	"registry"
+)
+
+var userKey = quarry.NewKey[string]("typedUser")
+
+func init() {
+	q := registry.Default()
+
+	q.MustAddFactory("userService", quarry.Singleton(buildUserService))
+
+	q.MustAddFactory("user", fetchUser)
+	q.MustAddDependency("user", "userStorage")
+	q.MustAddEdge("user", "sessionStore", quarry.As("sessions"))
+	q.MustAddEdge("user", "audit", quarry.WhenResolved(isAdmin, "admins"), quarry.Optional())
+
+	q.MustAddFactory("userService", quarry.Provider(nil)) // want `userService is already registered at .*users.go:\d+:\d+`
+
+	q.MustAddFactory("cache", quarry.Provider(nil))
+
+	quarry.MustAddTypedFactory(q, userKey, func(ctx context.Context, params interface{}, deps quarry.Dependencies) (string, error) {
+		return deps["user"].(string), nil
+	})
+
+	local := quarry.New()
+	local.MustAddFactory("user", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		return deps["userStorage"], nil
+	})
+}
+
+func buildUserService(ctx context.Context, deps quarry.Dependencies) (interface{}, error) {
+	return deps["userStorage"], nil
+}
+
+func fetchUser(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+	_ = deps["userStorage"]
+	_ = deps["sessions"]
+	_ = deps["audit"]
+	_ = deps["sessionStore"]
+	return nil, nil
+}
+
+func isAdmin(ctx context.Context, params interface{}, prerequisites quarry.Dependencies) bool {
+	return prerequisites["admins"] != nil
+}
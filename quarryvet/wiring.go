@@ -0,0 +1,557 @@
+package quarryvet
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// quarryPath is the import path of the quarry package.
+const quarryPath = "github.com/explodes/quarry"
+
+// wiring is a fact holding the nodes registered and the dependencies
+// declared by a package and by the packages it imports.
+type wiring struct {
+	Registrations []registration
+	Dependencies  []dependency
+	Reads         []read
+	// DynamicNodes is set when nodes are registered under names that are not
+	// constants, or installed from a Module.
+	DynamicNodes bool
+	// DynamicEdges is set when dependencies are declared for parents whose
+	// names are not constants.
+	DynamicEdges bool
+	// Graphs maps functions that return a shared Quarry, such as a package's
+	// Default function, to the Quarry they return.
+	Graphs map[string]string
+
+	seen map[string]bool
+}
+
+func (*wiring) AFact() {}
+
+func (w *wiring) String() string {
+	return fmt.Sprintf("wiring(%d registrations, %d dependencies)", len(w.Registrations), len(w.Dependencies))
+}
+
+// registration is a node registered under a constant name.
+type registration struct {
+	Name string
+	// Graph identifies the Quarry the node is registered in, when it is
+	// shared between packages, such as through a package variable.
+	Graph string
+	// Pos is where the node is registered.
+	Pos string
+}
+
+// dependency is a dependency declared for a parent with a constant name.
+type dependency struct {
+	Parent    string
+	DependsOn string
+	// Key is the key the dependency is delivered under, or empty for the
+	// prerequisites of a condition, which are not delivered to the parent.
+	Key      string
+	Optional bool
+	// Dynamic is set when the name of the node depended upon, its key or its
+	// options are not constants.
+	Dynamic bool
+	// Pos is where the dependency is declared.
+	Pos string
+}
+
+// read is a Factory of a node with a constant name reading a dependency
+// under a constant key.
+type read struct {
+	Parent string
+	Key    string
+	// Variable is the name of the Dependencies read from.
+	Variable string
+	// Pos is where the dependency is read.
+	Pos string
+}
+
+// add merges another wiring into w, skipping what it already holds.
+func (w *wiring) add(other *wiring) {
+	if w.seen == nil {
+		w.seen = make(map[string]bool)
+	}
+	for _, r := range other.Registrations {
+		if key := "r" + r.Pos + r.Name; !w.seen[key] {
+			w.seen[key] = true
+			w.Registrations = append(w.Registrations, r)
+		}
+	}
+	for _, d := range other.Dependencies {
+		if key := "d" + d.Pos + d.Parent + "\x00" + d.DependsOn; !w.seen[key] {
+			w.seen[key] = true
+			w.Dependencies = append(w.Dependencies, d)
+		}
+	}
+	for _, r := range other.Reads {
+		if key := "k" + r.Pos + r.Parent + "\x00" + r.Key; !w.seen[key] {
+			w.seen[key] = true
+			w.Reads = append(w.Reads, r)
+		}
+	}
+	for function, graph := range other.Graphs {
+		if w.Graphs == nil {
+			w.Graphs = make(map[string]string)
+		}
+		w.Graphs[function] = graph
+	}
+	w.DynamicNodes = w.DynamicNodes || other.DynamicNodes
+	w.DynamicEdges = w.DynamicEdges || other.DynamicEdges
+}
+
+// contains returns true if w holds r.
+func (w *wiring) contains(r registration) bool {
+	for _, other := range w.Registrations {
+		if other.Pos == r.Pos && other.Name == r.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// scanner collects the wiring of the current package.
+type scanner struct {
+	pass   *analysis.Pass
+	wiring *wiring
+	// funcs are the functions declared in the package.
+	funcs map[*types.Func]*ast.FuncDecl
+	// inits are the expressions variables of the package are initialized with.
+	inits map[*types.Var]ast.Expr
+	// graphs maps functions that return a shared Quarry to the Quarry they
+	// return, for the package and the packages it imports.
+	graphs map[string]string
+	// positions maps the positions of the registrations, dependencies and
+	// reads of the package to where they are in its files.
+	positions map[string]token.Pos
+}
+
+func newScanner(pass *analysis.Pass, imported *wiring) *scanner {
+	s := &scanner{
+		pass:      pass,
+		wiring:    &wiring{},
+		funcs:     make(map[*types.Func]*ast.FuncDecl),
+		inits:     make(map[*types.Var]ast.Expr),
+		graphs:    make(map[string]string),
+		positions: make(map[string]token.Pos),
+	}
+	for function, graph := range imported.Graphs {
+		s.graphs[function] = graph
+	}
+	return s
+}
+
+func (s *scanner) scan() {
+	info := s.pass.TypesInfo
+	for _, file := range s.pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if fn, ok := info.Defs[n.Name].(*types.Func); ok {
+					s.funcs[fn] = n
+				}
+			case *ast.ValueSpec:
+				if len(n.Names) == len(n.Values) {
+					for i, name := range n.Names {
+						s.define(name, n.Values[i])
+					}
+				}
+			case *ast.AssignStmt:
+				if n.Tok == token.DEFINE && len(n.Lhs) == len(n.Rhs) {
+					for i, lhs := range n.Lhs {
+						if name, ok := lhs.(*ast.Ident); ok {
+							s.define(name, n.Rhs[i])
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	for fn, decl := range s.funcs {
+		s.accessor(fn, decl)
+	}
+	for _, file := range s.pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				s.call(call)
+			}
+			return true
+		})
+	}
+}
+
+func (s *scanner) define(name *ast.Ident, value ast.Expr) {
+	if v, ok := s.pass.TypesInfo.Defs[name].(*types.Var); ok {
+		s.inits[v] = value
+	}
+}
+
+// accessor records the Quarry a function returns, if it has no parameters
+// and always returns the same shared Quarry.
+func (s *scanner) accessor(fn *types.Func, decl *ast.FuncDecl) {
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() != nil || sig.Params().Len() > 0 || decl.Body == nil || len(decl.Body.List) != 1 {
+		return
+	}
+	ret, ok := decl.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return
+	}
+	if graph := s.graphOf(ret.Results[0]); graph != "" {
+		if s.wiring.Graphs == nil {
+			s.wiring.Graphs = make(map[string]string)
+		}
+		s.graphs[fn.FullName()+"()"] = graph
+		s.wiring.Graphs[fn.FullName()+"()"] = graph
+	}
+}
+
+// call records the wiring done by a call to the quarry package.
+func (s *scanner) call(call *ast.CallExpr) {
+	fn := s.callee(call)
+	if fn == nil {
+		return
+	}
+	args := call.Args
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		switch fn.Name() {
+		case "AddTypedFactory", "MustAddTypedFactory":
+			if name, ok := s.keyName(args[1]); ok {
+				s.register(s.graphOf(args[0]), name, args[1])
+				s.factory(name, args[2])
+			} else {
+				s.wiring.DynamicNodes = true
+			}
+		}
+		return
+	}
+	if !isQuarryType(recv.Type(), "Quarry") {
+		return
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok || s.pass.TypesInfo.Selections[sel] == nil || s.pass.TypesInfo.Selections[sel].Kind() != types.MethodVal {
+		// Method values and expressions, such as quarry.Quarry.AddFactory,
+		// are not followed.
+		return
+	}
+	graph := s.graphOf(sel.X)
+	spread := call.Ellipsis.IsValid()
+	switch fn.Name() {
+	case "AddFactory", "MustAddFactory":
+		if name, ok := s.registerExpr(graph, args[0]); ok {
+			s.factory(name, args[1])
+		}
+	case "AddFallback", "MustAddFallback":
+		if name, ok := s.constant(args[0]); ok {
+			s.factory(name, args[1])
+		}
+	case "AddConstructor", "MustAddConstructor":
+		s.registerExpr(graph, args[0])
+		if spread {
+			s.dependency(args[0], nil, nil, true)
+			return
+		}
+		for _, dependsOn := range args[2:] {
+			s.dependency(args[0], dependsOn, nil, false)
+		}
+	case "AddDependency", "MustAddDependency":
+		s.dependency(args[0], args[1], nil, false)
+	case "AddEdge", "MustAddEdge":
+		s.dependency(args[0], args[1], args[2:], spread)
+	case "AddSwitch", "MustAddSwitch":
+		s.registerExpr(graph, args[0])
+		if spread {
+			s.dependency(args[0], nil, nil, true)
+			return
+		}
+		for _, c := range args[1:] {
+			s.dependency(args[0], s.caseTarget(c), nil, false)
+		}
+	case "AddAlias", "MustAddAlias":
+		s.registerExpr(graph, args[0])
+		s.dependency(args[0], args[1], nil, false)
+	case "Install", "MustInstall":
+		s.wiring.DynamicNodes = true
+	}
+}
+
+// callee returns the function of the quarry package called, or nil.
+func (s *scanner) callee(call *ast.CallExpr) *types.Func {
+	fn, ok := typeutil.Callee(s.pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != quarryPath {
+		return nil
+	}
+	return fn
+}
+
+// registerExpr records the registration of the node named by expr.
+func (s *scanner) registerExpr(graph string, expr ast.Expr) (string, bool) {
+	name, ok := s.constant(expr)
+	if !ok {
+		s.wiring.DynamicNodes = true
+		return "", false
+	}
+	s.register(graph, name, expr)
+	return name, true
+}
+
+func (s *scanner) register(graph, name string, at ast.Node) {
+	s.wiring.Registrations = append(s.wiring.Registrations, registration{
+		Name:  name,
+		Graph: graph,
+		Pos:   s.position(at),
+	})
+}
+
+// dependency records the dependency of parent on dependsOn, which is nil
+// when it is not known.
+func (s *scanner) dependency(parent, dependsOn ast.Expr, options []ast.Expr, spread bool) {
+	p, ok := s.constant(parent)
+	if !ok {
+		s.wiring.DynamicEdges = true
+		return
+	}
+	d := dependency{Parent: p, Dynamic: spread, Pos: s.position(parent)}
+	if dependsOn != nil {
+		d.DependsOn, ok = s.constant(dependsOn)
+		d.Dynamic = d.Dynamic || !ok
+		d.Pos = s.position(dependsOn)
+	} else {
+		d.Dynamic = true
+	}
+	d.Key = d.DependsOn
+	var prerequisites []ast.Expr
+	for _, option := range options {
+		call, ok := ast.Unparen(option).(*ast.CallExpr)
+		var fn *types.Func
+		if ok {
+			fn = s.callee(call)
+		}
+		if fn == nil {
+			// An option that is not a call to the quarry package could
+			// do anything.
+			d.Dynamic = true
+			continue
+		}
+		switch fn.Name() {
+		case "Optional":
+			d.Optional = true
+		case "As":
+			key, ok := s.constant(call.Args[0])
+			d.Key = key
+			d.Dynamic = d.Dynamic || !ok
+		case "WhenResolved":
+			prerequisites = append(prerequisites, call.Args[1:]...)
+			d.Dynamic = d.Dynamic || call.Ellipsis.IsValid()
+		}
+	}
+	s.wiring.Dependencies = append(s.wiring.Dependencies, d)
+	for _, prerequisite := range prerequisites {
+		name, ok := s.constant(prerequisite)
+		s.wiring.Dependencies = append(s.wiring.Dependencies, dependency{
+			Parent:    p,
+			DependsOn: name,
+			Optional:  d.Optional,
+			Dynamic:   !ok,
+			Pos:       s.position(prerequisite),
+		})
+	}
+}
+
+// caseTarget returns the target of a SwitchCase created by Case or Default,
+// or nil if it is not known.
+func (s *scanner) caseTarget(expr ast.Expr) ast.Expr {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	switch fn := s.callee(call); {
+	case fn == nil:
+		return nil
+	case fn.Name() == "Case":
+		return call.Args[1]
+	case fn.Name() == "Default":
+		return call.Args[0]
+	}
+	return nil
+}
+
+// factory records the dependencies read by the function registered as a
+// Factory of the named node, if it is declared in the package.
+func (s *scanner) factory(name string, expr ast.Expr) {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.FuncLit:
+		s.reads(name, expr.Type, expr.Body)
+	case *ast.Ident, *ast.SelectorExpr:
+		fn, ok := s.pass.TypesInfo.Uses[selected(expr)].(*types.Func)
+		if !ok {
+			return
+		}
+		if decl, ok := s.funcs[fn]; ok && decl.Body != nil {
+			s.reads(name, decl.Type, decl.Body)
+		}
+	case *ast.CallExpr:
+		if len(expr.Args) != 1 {
+			return
+		}
+		// Look through Singleton and conversions such as to Factory.
+		if fn := s.callee(expr); fn != nil && fn.Name() == "Singleton" || s.pass.TypesInfo.Types[expr.Fun].IsType() {
+			s.factory(name, expr.Args[0])
+		}
+	}
+}
+
+// reads records the dependencies a Factory of the named node reads from its
+// Dependencies parameters under constant keys.
+func (s *scanner) reads(name string, typ *ast.FuncType, body *ast.BlockStmt) {
+	info := s.pass.TypesInfo
+	for _, deps := range dependenciesParams(info, typ) {
+		ast.Inspect(body, func(n ast.Node) bool {
+			index, ok := n.(*ast.IndexExpr)
+			if !ok {
+				return true
+			}
+			id, ok := index.X.(*ast.Ident)
+			if !ok || info.Uses[id] != deps {
+				return true
+			}
+			if key, ok := stringValue(info, index.Index); ok {
+				s.wiring.Reads = append(s.wiring.Reads, read{
+					Parent:   name,
+					Key:      key,
+					Variable: id.Name,
+					Pos:      s.position(index),
+				})
+			}
+			return true
+		})
+	}
+}
+
+// dependenciesParams returns the parameters of a function of type Dependencies.
+func dependenciesParams(info *types.Info, typ *ast.FuncType) []types.Object {
+	var params []types.Object
+	for _, field := range typ.Params.List {
+		if !isQuarryType(info.TypeOf(field.Type), "Dependencies") {
+			continue
+		}
+		for _, name := range field.Names {
+			if obj := info.Defs[name]; obj != nil {
+				params = append(params, obj)
+			}
+		}
+	}
+	return params
+}
+
+// keyName returns the name of a Key created by NewKey with a constant name.
+func (s *scanner) keyName(expr ast.Expr) (string, bool) {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		if v, ok := s.pass.TypesInfo.Uses[selected(expr)].(*types.Var); ok {
+			if init, ok := s.inits[v]; ok {
+				return s.keyName(init)
+			}
+		}
+	case *ast.CallExpr:
+		if fn := s.callee(expr); fn != nil && fn.Name() == "NewKey" {
+			return s.constant(expr.Args[0])
+		}
+	}
+	return "", false
+}
+
+// graphOf identifies the Quarry expr evaluates to, when it is shared between
+// packages: a package variable, or the result of a function without
+// arguments, such as Default, possibly through a variable. It returns an
+// empty string otherwise.
+func (s *scanner) graphOf(expr ast.Expr) string {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		v, ok := s.pass.TypesInfo.Uses[selected(expr)].(*types.Var)
+		if !ok {
+			return ""
+		}
+		if init, ok := s.inits[v]; ok {
+			if graph := s.graphOf(init); graph != "" {
+				return graph
+			}
+		}
+		if v.Pkg() != nil && v.Pkg().Scope().Lookup(v.Name()) == v {
+			return v.Pkg().Path() + "." + v.Name()
+		}
+	case *ast.CallExpr:
+		fn, ok := typeutil.Callee(s.pass.TypesInfo, expr).(*types.Func)
+		// Functions of the quarry package, such as New and Child, create a
+		// new Quarry each time.
+		if ok && len(expr.Args) == 0 && fn.Pkg() != nil && fn.Pkg().Path() != quarryPath {
+			if graph, ok := s.graphs[fn.FullName()+"()"]; ok {
+				return graph
+			}
+			return fn.FullName() + "()"
+		}
+	}
+	return ""
+}
+
+// constant returns the value of a constant string expression.
+func (s *scanner) constant(expr ast.Expr) (string, bool) {
+	if expr == nil {
+		return "", false
+	}
+	return stringValue(s.pass.TypesInfo, expr)
+}
+
+// position describes where n is, remembering it so that it can be reported.
+func (s *scanner) position(n ast.Node) string {
+	position := s.pass.Fset.Position(n.Pos()).String()
+	s.positions[position] = n.Pos()
+	return position
+}
+
+// local returns where a registration, dependency or read is in the files of the
+// package, or token.NoPos if it is in another package.
+func (s *scanner) local(position string) token.Pos {
+	return s.positions[position]
+}
+
+// stringValue returns the value of a constant string expression.
+func stringValue(info *types.Info, expr ast.Expr) (string, bool) {
+	value := info.Types[expr].Value
+	if value == nil || value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(value), true
+}
+
+// selected returns the identifier an identifier or selector refers to.
+func selected(expr ast.Expr) *ast.Ident {
+	if sel, ok := expr.(*ast.SelectorExpr); ok {
+		return sel.Sel
+	}
+	return expr.(*ast.Ident)
+}
+
+// isQuarryType returns true if t, or what it points to, is the named type of
+// the quarry package.
+func isQuarryType(t types.Type, name string) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == quarryPath && obj.Name() == name
+}
@@ -0,0 +1,75 @@
+package quarry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how a failing Factory is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the Factory is called.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the delay between attempts, when positive.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each attempt. Values below 1 are treated as 2.
+	Multiplier float64
+	// Jitter randomly shortens each delay by up to this fraction of it,
+	// between 0 and 1, so that callers do not retry in lockstep.
+	Jitter float64
+	// Retryable reports whether an error should be retried.
+	// When nil, every error except a panic is retried.
+	Retryable func(err error) bool
+}
+
+// WithRetry retries a failing Factory according to a RetryPolicy.
+// Retries stop early when the resolution's Context is done. When a Factory
+// also has a timeout, the timeout applies to each attempt.
+func WithRetry(policy RetryPolicy) FactoryOption {
+	return func(n *node) {
+		n.retry = &policy
+	}
+}
+
+// shouldRetry returns true if another attempt should follow a failed attempt.
+func (p *RetryPolicy) shouldRetry(attempts int, err error) bool {
+	if p == nil || attempts >= p.MaxAttempts {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	_, panicked := err.(*PanicError)
+	return !panicked
+}
+
+// backoff returns the delay after the given number of attempts.
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempts-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// sleep waits for a duration, returning early with an error if the Context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,110 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRetry_succeedsAfterFailures(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(2)
+	q.MustAddFactory("root", flaky, quarry.WithRetry(quarry.RetryPolicy{MaxAttempts: 3}))
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", value)
+	assert.Equal(t, int32(3), *calls)
+}
+
+func TestWithRetry_recordsAttempts(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(5)
+	q.MustAddFactory("root", flaky, quarry.WithRetry(quarry.RetryPolicy{MaxAttempts: 3}))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, 3, resolutionErr.Attempts)
+	assert.Equal(t, int32(3), *calls)
+}
+
+func TestWithRetry_nonRetryableErrorIsNotRetried(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(5)
+	q.MustAddFactory("root", flaky, quarry.WithRetry(quarry.RetryPolicy{
+		MaxAttempts: 3,
+		Retryable:   func(error) bool { return false },
+	}))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), *calls)
+}
+
+func TestWithRetry_stopsWhenContextDone(t *testing.T) {
+	q := quarry.New()
+	calls, flaky := factoryFlaky(5)
+	q.MustAddFactory("root", flaky, quarry.WithRetry(quarry.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+	}))
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelFunc()
+
+	_, err := q.Get(ctx, nil, "root")
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), *calls)
+}
+
+func TestWithRetry_panicIsNotRetried(t *testing.T) {
+	q := quarry.New()
+	var calls int32
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		panic("some panic")
+	}, quarry.WithRetry(quarry.RetryPolicy{MaxAttempts: 3}))
+
+	_, err := q.Get(context.Background(), nil, "root")
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestWithRetry_appliesTimeoutPerAttempt(t *testing.T) {
+	q := quarry.New()
+	var calls int32
+	q.MustAddFactory("root", func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "ok", nil
+	}, quarry.WithTimeout(10*time.Millisecond), quarry.WithRetry(quarry.RetryPolicy{MaxAttempts: 2}))
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", value)
+}
+
+// factoryFlaky creates a Factory that fails a number of times before succeeding.
+func factoryFlaky(failures int32) (*int32, quarry.Factory) {
+	var calls int32
+	factory := func(ctx context.Context, params interface{}, deps quarry.Dependencies) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			return nil, errors.New("some-error")
+		}
+		return "ok", nil
+	}
+	return &calls, factory
+}
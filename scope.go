@@ -0,0 +1,93 @@
+package quarry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// epochs numbers the changes made by Override, across every quarry, so that
+// a later change always has a greater epoch.
+var epochs atomic.Uint64
+
+// scope identifies which values of a Singleton a Factory uses.
+type scope struct {
+	// q is the quarry whose values are used, or nil when the values are
+	// shared by every quarry.
+	q *quarryImpl
+	// epoch is the greatest epoch of the node and anything it transitively
+	// depends on. Values created in an earlier epoch are discarded.
+	epoch uint64
+}
+
+// scopeKey is the Context key of the scope of a Factory.
+type scopeKey struct{}
+
+// withScope returns a Context that makes Singletons use the values of s.
+func withScope(ctx context.Context, s scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// scopeFrom returns the scope of a Factory.
+func scopeFrom(ctx context.Context) scope {
+	if ctx == nil {
+		return scope{}
+	}
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s
+}
+
+// scopeDepth returns how many parents the quarry of a scope has.
+func scopeDepth(q *quarryImpl) int {
+	if q == nil {
+		return 0
+	}
+	return q.depth
+}
+
+// scoped returns true if factories of the quarry may need a scope: it is a
+// child, or it has been changed by Override.
+func (q *quarryImpl) scoped() bool {
+	if q.parent != nil {
+		return true
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.epochs) > 0
+}
+
+// scope returns the scope of the named node: the nearest quarry that
+// overrides the node or anything it transitively depends on, and the
+// greatest epoch among them.
+func (o *onceController) scope(name string) scope {
+	if !o.scoped {
+		return scope{}
+	}
+	o.m.Lock()
+	s, ok := o.scopes[name]
+	o.m.Unlock()
+	if ok {
+		return s
+	}
+	for q := o.q; q != nil; q = q.parent {
+		if q.parent != nil && s.q == nil && q.overrides(name) {
+			s.q = q
+		}
+		if epoch := q.epoch(name); epoch > s.epoch {
+			s.epoch = epoch
+		}
+	}
+	n, edges, _ := o.q.node(name)
+	for _, dependsOn := range successorsOf(n, edges) {
+		dependency := o.scope(dependsOn)
+		if scopeDepth(dependency.q) > scopeDepth(s.q) {
+			s.q = dependency.q
+		}
+		if dependency.epoch > s.epoch {
+			s.epoch = dependency.epoch
+		}
+	}
+	o.m.Lock()
+	o.scopes[name] = s
+	o.m.Unlock()
+	return s
+}
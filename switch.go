@@ -0,0 +1,115 @@
+package quarry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoMatchingCase is the cause of a resolution error when no case of a
+// switch without a default case is met.
+var ErrNoMatchingCase = errors.New("no case matched")
+
+// SwitchCase selects the node a switch resolves to. See AddSwitch.
+type SwitchCase struct {
+	// predicate must be met for target to be chosen. It is nil for the default case.
+	predicate Predicate
+	target    string
+}
+
+// Case creates a SwitchCase that chooses target when predicate is met.
+func Case(predicate Predicate, target string) SwitchCase {
+	return SwitchCase{predicate: predicate, target: target}
+}
+
+// Default creates a SwitchCase that chooses target when no other case is met.
+func Default(target string) SwitchCase {
+	return SwitchCase{target: target}
+}
+
+func (q *quarryImpl) MustAddSwitch(name string, cases ...SwitchCase) {
+	if err := q.AddSwitch(name, cases...); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) AddSwitch(name string, cases ...SwitchCase) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.frozen.Load() {
+		return fmt.Errorf("cannot add switch %s to a frozen quarry", name)
+	}
+	if _, exists := q.factories[name]; exists {
+		return fmt.Errorf("duplicate add of factory %s", name)
+	}
+	if q.adjacency[name].Size() > 0 {
+		return fmt.Errorf("switch %s cannot have dependencies", name)
+	}
+	if len(cases) == 0 {
+		return fmt.Errorf("switch %s has no cases", name)
+	}
+	var defaults int
+	for _, c := range cases {
+		if c.predicate == nil {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return fmt.Errorf("switch %s has %d default cases", name, defaults)
+	}
+
+	var linked []string
+	for _, c := range cases {
+		if q.successors[name].Contains(c.target) {
+			continue
+		}
+		if path := q.findCycle(name, c.target); path != nil {
+			for _, target := range linked {
+				q.unlink(name, target)
+			}
+			return &CycleError{Parent: name, DependsOn: c.target, Path: path}
+		}
+		q.link(name, c.target)
+		linked = append(linked, c.target)
+	}
+	q.factories[name] = &node{cases: cases}
+	return nil
+}
+
+// isSwitch returns true if the node was registered with AddSwitch.
+func (n *node) isSwitch() bool {
+	return n.cases != nil
+}
+
+// targets returns the names of the nodes a switch may resolve to.
+func (n *node) targets() []string {
+	targets := make([]string, len(n.cases))
+	for i, c := range n.cases {
+		targets[i] = c.target
+	}
+	return targets
+}
+
+// chooseCase returns the target of the first case that is met, or of the
+// default case if none are, converting a panic into a *PanicError.
+func chooseCase(ctx context.Context, name string, params interface{}, cases []SwitchCase) (target string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			target, err = "", newPanicError(name, r)
+		}
+	}()
+	var defaultTarget string
+	for _, c := range cases {
+		if c.predicate == nil {
+			defaultTarget = c.target
+			continue
+		}
+		if c.predicate.Evaluate(ctx, params) {
+			return c.target, nil
+		}
+	}
+	if defaultTarget == "" {
+		return "", ErrNoMatchingCase
+	}
+	return defaultTarget, nil
+}
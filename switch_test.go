@@ -0,0 +1,144 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func storageSwitch() (q quarry.Quarry, memoryCount, sqlCount *int32) {
+	q = quarry.New()
+	memoryCount, memory := factoryCounter()
+	sqlCount, sql := factoryCounter()
+	q.MustAddFactory("memoryUserStorage", memory)
+	q.MustAddFactory("sqlUserStorage", sql)
+	q.MustAddSwitch("userStorage",
+		quarry.Case(quarry.Condition(func(params interface{}) bool { return params == "test" }), "memoryUserStorage"),
+		quarry.Default("sqlUserStorage"))
+	return q, memoryCount, sqlCount
+}
+
+func TestQuarryImpl_AddSwitch(t *testing.T) {
+	tests := []struct {
+		params      string
+		memoryCount int32
+		sqlCount    int32
+	}{
+		{"test", 1, 0},
+		{"prod", 0, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.params, func(t *testing.T) {
+			q, memoryCount, sqlCount := storageSwitch()
+
+			value, err := q.Get(context.Background(), test.params, "userStorage")
+
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), value)
+			assert.Equal(t, test.memoryCount, *memoryCount)
+			assert.Equal(t, test.sqlCount, *sqlCount)
+		})
+	}
+}
+
+func TestQuarryImpl_AddSwitch_sharedWithinResolution(t *testing.T) {
+	q, _, sqlCount := storageSwitch()
+	q.MustAddFactory("root", factoryDeps())
+	q.MustAddDependency("root", "userStorage")
+	q.MustAddDependency("root", "sqlUserStorage")
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.Equal(t, quarry.Dependencies{"userStorage": int32(1), "sqlUserStorage": int32(1)}, value)
+	assert.Equal(t, int32(1), *sqlCount)
+}
+
+func TestQuarryImpl_AddSwitch_noMatchingCase(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddSwitch("switch", quarry.Case(no, "a"))
+
+	_, err := q.Get(context.Background(), nil, "switch")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, "switch", resolutionErr.Node)
+	assert.True(t, errors.Is(err, quarry.ErrNoMatchingCase))
+}
+
+func TestQuarryImpl_AddSwitch_targetFailure(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryError())
+	q.MustAddSwitch("switch", quarry.Default("a"))
+
+	_, err := q.Get(context.Background(), nil, "switch")
+
+	var resolutionErr *quarry.ResolutionError
+	assert.True(t, errors.As(err, &resolutionErr))
+	assert.Equal(t, []string{"switch", "a"}, resolutionErr.Path)
+}
+
+func TestQuarryImpl_AddSwitch_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(q quarry.Quarry) error
+		err   string
+	}{
+		{"no cases", func(q quarry.Quarry) error {
+			return q.AddSwitch("switch")
+		}, "switch switch has no cases"},
+		{"multiple defaults", func(q quarry.Quarry) error {
+			return q.AddSwitch("switch", quarry.Default("a"), quarry.Default("b"))
+		}, "switch switch has 2 default cases"},
+		{"duplicate", func(q quarry.Quarry) error {
+			q.MustAddFactory("switch", factoryOk())
+			return q.AddSwitch("switch", quarry.Default("a"))
+		}, "duplicate add of factory switch"},
+		{"dependencies before", func(q quarry.Quarry) error {
+			q.MustAddDependency("switch", "a")
+			return q.AddSwitch("switch", quarry.Default("a"))
+		}, "switch switch cannot have dependencies"},
+		{"dependencies after", func(q quarry.Quarry) error {
+			q.MustAddSwitch("switch", quarry.Default("a"))
+			return q.AddDependency("switch", "b")
+		}, "switch switch cannot have dependencies"},
+		{"cycle", func(q quarry.Quarry) error {
+			q.MustAddDependency("a", "switch")
+			return q.AddSwitch("switch", quarry.Case(yes, "b"), quarry.Default("a"))
+		}, "depending switch on a creates a cycle: switch -> a -> switch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := quarry.New()
+
+			err := test.setup(q)
+
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestQuarryImpl_AddSwitch_cycleRollsBack(t *testing.T) {
+	q := quarry.New()
+	q.MustAddDependency("a", "switch")
+	_ = q.AddSwitch("switch", quarry.Case(yes, "b"), quarry.Default("a"))
+
+	err := q.AddDependency("b", "switch")
+
+	assert.NoError(t, err)
+	assert.False(t, q.Has("switch"))
+}
+
+func TestQuarryImpl_AddSwitch_validatesTargets(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddSwitch("switch", quarry.Case(yes, "a"), quarry.Default("missing"))
+
+	err := q.Validate()
+
+	assert.EqualError(t, err, "invalid quarry: factory missing, depended upon by switch, does not exist")
+}
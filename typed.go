@@ -0,0 +1,122 @@
+package quarry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Key identifies a node by name and the type of value its Factory produces.
+// Keys let callers fetch values without type-asserting interface{} results.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a Key for the node with the given name.
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
+}
+
+// Name returns the name of the node this Key refers to.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+func (k Key[T]) String() string {
+	return fmt.Sprintf("%s(%s)", k.name, typeName[T]())
+}
+
+// TypedFactory is a Factory that produces a value of a known type.
+type TypedFactory[T any] func(ctx context.Context, params interface{}, deps Dependencies) (T, error)
+
+// Factory converts a TypedFactory to a Factory.
+func (f TypedFactory[T]) Factory() Factory {
+	return func(ctx context.Context, params interface{}, deps Dependencies) (interface{}, error) {
+		return f(ctx, params, deps)
+	}
+}
+
+// AddTypedFactory registers a TypedFactory under the name of the given Key.
+// The type is recorded so that constructors can depend on it by type.
+func AddTypedFactory[T any](q Quarry, key Key[T], factory TypedFactory[T], options ...FactoryOption) error {
+	if err := q.AddFactory(key.name, factory.Factory(), options...); err != nil {
+		return err
+	}
+	if registry, ok := q.(typeRegistry); ok {
+		registry.setType(key.name, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return nil
+}
+
+// MustAddTypedFactory panics if AddTypedFactory fails.
+func MustAddTypedFactory[T any](q Quarry, key Key[T], factory TypedFactory[T], options ...FactoryOption) {
+	if err := AddTypedFactory(q, key, factory, options...); err != nil {
+		panic(err)
+	}
+}
+
+// Get fetches the value for a Key using the parameters provided.
+// An error is returned if resolution fails or if the node produced a value
+// of a different type than the Key describes.
+func Get[T any](ctx context.Context, q Quarry, params interface{}, key Key[T]) (T, error) {
+	value, err := q.Get(ctx, params, key.name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return convert[T](key.name, value)
+}
+
+// MustGet panics if Get fails.
+func MustGet[T any](ctx context.Context, q Quarry, params interface{}, key Key[T]) T {
+	value, err := Get(ctx, q, params, key)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Dep fetches the value for a Key from Dependencies.
+// An error is returned if the dependency was not provided or if it is of a
+// different type than the Key describes. A nil dependency, such as one whose
+// conditions were not met, results in the zero value.
+func Dep[T any](deps Dependencies, key Key[T]) (T, error) {
+	value, ok := deps[key.name]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("dependency %s was not provided", key.name)
+	}
+	return convert[T](key.name, value)
+}
+
+// MustDep panics if Dep fails.
+func MustDep[T any](deps Dependencies, key Key[T]) T {
+	value, err := Dep(deps, key)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// convert asserts that value is a T, treating nil as the zero value.
+func convert[T any](name string, value interface{}) (T, error) {
+	var zero T
+	if value == nil {
+		return zero, nil
+	}
+	result, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("node %s produced %T, want %s", name, value, typeName[T]())
+	}
+	return result, nil
+}
+
+// typeRegistry records the types of values produced by factories.
+type typeRegistry interface {
+	setType(name string, t reflect.Type)
+}
+
+// typeName returns the name of the type T.
+func typeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
@@ -0,0 +1,90 @@
+package quarry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey_Name(t *testing.T) {
+	key := quarry.NewKey[string]("some-key")
+
+	assert.Equal(t, "some-key", key.Name())
+}
+
+func TestGet_returnsTypedValue(t *testing.T) {
+	key := quarry.NewKey[string]("greeting")
+	q := quarry.New()
+	quarry.MustAddTypedFactory(q, key, func(ctx context.Context, params interface{}, deps quarry.Dependencies) (string, error) {
+		return "hello", nil
+	})
+
+	value, err := quarry.Get(context.Background(), q, nil, key)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", value)
+}
+
+func TestGet_typeMismatchResultsInError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("number", quarry.Provider(1))
+
+	_, err := quarry.Get(context.Background(), q, nil, quarry.NewKey[string]("number"))
+
+	assert.Error(t, err)
+}
+
+func TestGet_nilResultsInZeroValue(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("nothing", quarry.Provider(nil))
+
+	value, err := quarry.Get(context.Background(), q, nil, quarry.NewKey[*int]("nothing"))
+
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestMustGet_panicsOnError(t *testing.T) {
+	q := quarry.New()
+	defer func() {
+		err := recover()
+		assert.NotNil(t, err)
+	}()
+
+	quarry.MustGet(context.Background(), q, nil, quarry.NewKey[string]("missing"))
+}
+
+func TestDep_returnsTypedValue(t *testing.T) {
+	nameKey := quarry.NewKey[string]("name")
+	greetingKey := quarry.NewKey[string]("greeting")
+	q := quarry.New()
+	quarry.MustAddTypedFactory(q, nameKey, func(ctx context.Context, params interface{}, deps quarry.Dependencies) (string, error) {
+		return "world", nil
+	})
+	quarry.MustAddTypedFactory(q, greetingKey, func(ctx context.Context, params interface{}, deps quarry.Dependencies) (string, error) {
+		name, err := quarry.Dep(deps, nameKey)
+		return "hello " + name, err
+	})
+	q.MustAddDependency(greetingKey.Name(), nameKey.Name())
+
+	value, err := quarry.Get(context.Background(), q, nil, greetingKey)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", value)
+}
+
+func TestDep_missingResultsInError(t *testing.T) {
+	_, err := quarry.Dep(quarry.Dependencies{}, quarry.NewKey[string]("missing"))
+
+	assert.Error(t, err)
+}
+
+func TestDep_typeMismatchResultsInError(t *testing.T) {
+	deps := quarry.Dependencies{"number": 1}
+
+	_, err := quarry.Dep(deps, quarry.NewKey[string]("number"))
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package quarry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidationError reports every problem found while validating a graph.
+type ValidationError struct {
+	// Errors holds one error per problem found.
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid quarry: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap returns the individual problems so they can be matched with errors.Is and errors.As.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+func (q *quarryImpl) Validate() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.validate()
+}
+
+func (q *quarryImpl) MustFreeze() {
+	if err := q.Freeze(); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quarryImpl) Freeze() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.frozen.Load() {
+		return nil
+	}
+	if err := q.validate(); err != nil {
+		return err
+	}
+	q.frozen.Store(true)
+	return nil
+}
+
+// validate checks that every dependency that is not optional, and every
+// prerequisite of its conditions, can be fulfilled by a Factory, and that no
+// node of a Module is depended upon from outside of it unless it is exported.
+// Children are validated together with the nodes they inherit, including for
+// cycles.
+// The caller must hold mu.
+func (q *quarryImpl) validate() error {
+	var errs []error
+
+	names := make([]string, 0, len(q.factories))
+	for name := range q.names() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, parent := range names {
+		n, edges, ok := q.lookupLocked(parent)
+		successors := successorsOf(n, edges)
+		if len(successors) == 0 {
+			continue
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("factory %s has dependencies but does not exist", parent))
+		}
+		reported := newStringSet()
+		for _, dependsOn := range requiredOf(n, edges) {
+			if !q.exists(dependsOn) && !reported.Contains(dependsOn) {
+				reported.Add(dependsOn)
+				errs = append(errs, fmt.Errorf("factory %s, depended upon by %s, does not exist", dependsOn, parent))
+			}
+		}
+		for _, dependsOn := range successors {
+			if !q.reachable(parent, dependsOn) {
+				dependency, _, _ := q.lookupLocked(dependsOn)
+				errs = append(errs, fmt.Errorf("factory %s is not exported by module %s, but is depended upon by %s",
+					dependsOn, dependency.module, parent))
+			}
+		}
+	}
+
+	if q.parent != nil {
+		// A parent does not check its children's edges, so together they can
+		// form a cycle that neither rejected when it was added.
+		if cycle := q.findAnyCycle(names); cycle != nil {
+			errs = append(errs, &CycleError{Parent: cycle[0], DependsOn: cycle[1], Path: cycle})
+		}
+	}
+
+	missing := make(map[string]string)
+	for _, name := range names {
+		if !q.exists(name) || q.hasMissingDependency(name) {
+			// Already reported as a dangling dependency.
+			continue
+		}
+		if dependsOn := q.findMissing(missing, newStringSet(), name); dependsOn != "" {
+			errs = append(errs, fmt.Errorf("factory %s can never be resolved because it transitively depends on missing factory %s", name, dependsOn))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+// exists returns true if a Factory is registered under name.
+// The caller must hold mu.
+func (q *quarryImpl) exists(name string) bool {
+	_, _, ok := q.lookupLocked(name)
+	return ok
+}
+
+// hasMissingDependency returns true if name directly depends on a Factory that does not exist.
+// The caller must hold mu.
+func (q *quarryImpl) hasMissingDependency(name string) bool {
+	for _, dependsOn := range q.required(name) {
+		if !q.exists(dependsOn) {
+			return true
+		}
+	}
+	return false
+}
+
+// required returns the names of the nodes that name cannot be resolved without.
+// The caller must hold mu.
+func (q *quarryImpl) required(name string) []string {
+	n, edges, _ := q.lookupLocked(name)
+	return requiredOf(n, edges)
+}
+
+// requiredOf returns the names of the nodes that a node cannot be resolved
+// without: its dependencies that are not optional, and the prerequisites of
+// their conditions, or the targets of a switch or alias, in a stable order.
+func requiredOf(n *node, edges edgeMap) []string {
+	return dependenciesOf(n, edges, false)
+}
+
+// successorsOf returns the names of every node that a node may need to
+// resolve, including optional dependencies, in a stable order.
+func successorsOf(n *node, edges edgeMap) []string {
+	return dependenciesOf(n, edges, true)
+}
+
+func dependenciesOf(n *node, edges edgeMap, optional bool) []string {
+	switch {
+	case n != nil && n.isSwitch():
+		return n.targets()
+	case n != nil && n.isAlias():
+		return []string{n.alias}
+	}
+	var names []string
+	for _, dependsOn := range edges.sortedKeys() {
+		if e := edges[dependsOn]; optional || !e.optional {
+			names = append(names, e.requires(dependsOn)...)
+		}
+	}
+	return names
+}
+
+// findMissing returns the name of a missing Factory reachable from name, or
+// an empty string if there is none. Results are memoized in missing.
+// The caller must hold mu.
+func (q *quarryImpl) findMissing(missing map[string]string, visited stringSet, name string) string {
+	if result, ok := missing[name]; ok {
+		return result
+	}
+	if !q.exists(name) {
+		return name
+	}
+	visited.Add(name)
+	var result string
+	for _, dependsOn := range q.required(name) {
+		if visited.Contains(dependsOn) {
+			continue
+		}
+		if result = q.findMissing(missing, visited, dependsOn); result != "" {
+			break
+		}
+	}
+	missing[name] = result
+	return result
+}
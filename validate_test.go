@@ -0,0 +1,90 @@
+package quarry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/explodes/quarry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarryImpl_Validate_ok(t *testing.T) {
+	_, q := simpleGraph(nil)
+
+	err := q.Validate()
+
+	assert.NoError(t, err)
+}
+
+func TestQuarryImpl_Validate_reportsEveryProblem(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddDependency("root", "a")
+	q.MustAddDependency("a", "missing-a")
+	q.MustAddDependency("root", "missing-b")
+	q.MustAddDependency("unregistered", "a")
+
+	err := q.Validate()
+
+	var validationErr *quarry.ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Len(t, validationErr.Errors, 3)
+}
+
+func TestQuarryImpl_Validate_reportsTransitivelyMissing(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddFactory("a", factoryOk())
+	q.MustAddDependency("root", "a")
+	q.MustAddDependency("a", "missing")
+
+	err := q.Validate()
+
+	var validationErr *quarry.ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Len(t, validationErr.Errors, 2)
+}
+
+func TestQuarryImpl_Freeze_invalidGraphResultsInError(t *testing.T) {
+	q := quarry.New()
+	q.MustAddFactory("root", factoryOk())
+	q.MustAddDependency("root", "missing")
+
+	err := q.Freeze()
+
+	assert.Error(t, err)
+	assert.NoError(t, q.AddFactory("missing", factoryOk()))
+}
+
+func TestQuarryImpl_Freeze_rejectsRegistrations(t *testing.T) {
+	_, q := simpleGraph(nil)
+
+	err := q.Freeze()
+
+	assert.NoError(t, err)
+	assert.Error(t, q.AddFactory("new", factoryOk()))
+	assert.Error(t, q.AddDependency("root", "j"))
+}
+
+func TestQuarryImpl_MustFreeze_panicsOnError(t *testing.T) {
+	q := quarry.New()
+	defer func() {
+		err := recover()
+		assert.NotNil(t, err)
+	}()
+	q.MustAddDependency("root", "missing")
+
+	q.MustFreeze()
+}
+
+func TestQuarryImpl_Get_frozen(t *testing.T) {
+	_, q := simpleGraph(nil)
+	q.MustFreeze()
+
+	value, err := q.Get(context.Background(), nil, "root")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, value)
+}